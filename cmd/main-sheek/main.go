@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sheek/internal/config"
 	"sheek/internal/history"
 	"sheek/internal/tui"
@@ -17,15 +19,27 @@ import (
 
 type teaModel tui.Model
 
-func (m teaModel) Init() tea.Cmd { return tui.TickCmd() }
+func (m teaModel) Init() tea.Cmd { return tui.Init(tui.Model(m)) }
 func (m teaModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	nm, cmd := tui.Update(msg, tui.Model(m))
 	return teaModel(nm), cmd
 }
 func (m teaModel) View() string { return tui.View(tui.Model(m)) }
 
+// stringListFlag collects the values of a flag that may be repeated
+type stringListFlag []string
+
+func (f *stringListFlag) String() string { return fmt.Sprint(*f) }
+func (f *stringListFlag) Set(value string) error {
+	*f = append(*f, filepath.SplitList(value)...)
+	return nil
+}
+
 func main() {
 	queryFlag := flag.String("query", "", "prefill the search input with a query")
+	var historyFiles stringListFlag
+	flag.Var(&historyFiles, "history-file", "history file to read; repeat to merge several (overrides config and $HISTFILE)")
+	noCacheFlag := flag.Bool("no-cache", false, "parse history from scratch without using the on-disk cache")
 	flag.Parse()
 
 	initialQuery := *queryFlag
@@ -43,7 +57,26 @@ func main() {
 	// Initialize styles with config colors
 	styles.InitializeStyles(cfg)
 
-	cmds, err := history.LoadAndParseZshHistory()
+	// Resolve history files: --history-file, then config, then $HISTFILE or the shell's own file
+	shell := history.DetectShell()
+	historyPaths, err := history.HistoryPaths(shell, historyFiles, cfg.HistoryFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to locate history: %v\n", err)
+		os.Exit(1)
+	}
+
+	loadOptions := history.LoadOptions{
+		MaxLineLength:  cfg.MaxLineLength,
+		LongLinePolicy: history.LongLinePolicy(cfg.LongLines),
+	}
+	if cfg.Cache && !*noCacheFlag {
+		// Without a cache directory sheek still works, it just parses everything
+		if cacheDir, err := history.DefaultCacheDir(); err == nil {
+			loadOptions.CacheDir = cacheDir
+		}
+	}
+
+	cmds, watcher, err := history.LoadAndWatchHistoryFiles(historyPaths, shell, loadOptions)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed to load history: %v\n", err)
 		os.Exit(1)
@@ -90,8 +123,17 @@ func main() {
 	// Redirect bubbletea output to stderr so stdout is clean for command output
 	p := tea.NewProgram(teaModel(model), tea.WithOutput(os.Stderr))
 
+	// Feed commands run in other shells into the list while sheek is open
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	if cfg.Watch {
+		go watcher.Run(watchCtx, func(appended []history.Command) {
+			p.Send(tui.HistoryAppendedMsg{Commands: appended})
+		})
+	}
+
 	// Run the program
 	m, err := p.Run()
+	stopWatching()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error running program: %v\n", err)
 		os.Exit(1)
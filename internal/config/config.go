@@ -10,6 +10,20 @@ type ColorConfig struct {
 	Selected   string `json:"selected"`  // Selected item background (default: "#3A3A5C")
 	Highlight  string `json:"highlight"` // Highlight/accent color (default: "#FFD700")
 	Background string `json:"bg"`        // Background color (default: "#1A1A1A")
+	Error      string `json:"error"`     // Invalid query color (default: "#FF5F87")
+	Program    string `json:"program"`   // Program name color in commands (default: "#5FAFFF")
+	Flag       string `json:"flag"`      // Option color in commands (default: "#AF87FF")
+	String     string `json:"string"`    // Quoted string color in commands (default: "#87D787")
+	Variable   string `json:"variable"`  // Variable and substitution color in commands (default: "#FFAF5F")
+	Operator   string `json:"operator"`  // Pipe, redirection and control operator color in commands (default: "#FF87D7")
+	Comment    string `json:"comment"`   // Comment color in commands (default: "#808080")
+}
+
+// FrecencyConfig represents the weights of the frecency sort mode
+type FrecencyConfig struct {
+	Frequency     float64 `json:"frequency"`       // Points per doubling of a command's run count (default: 1)
+	Recency       float64 `json:"recency"`         // Points for a command run just now (default: 5)
+	HalfLifeHours float64 `json:"half_life_hours"` // Hours until the recency points halve (default: 168)
 }
 
 // Config represents the application configuration
@@ -19,10 +33,26 @@ type Config struct {
 	Height        int  `json:"height"`         // List container height (default: 12)
 	Margin        int  `json:"margin"`         // Horizontal margin (default: 1)
 	ShowTimestamp bool `json:"show_timestamp"` // Display command timestamp column (default: true)
+	ShowDuration  bool `json:"show_duration"`  // Display command duration column (default: false)
+
+	// Preview
+	Preview         bool   `json:"preview"`          // Show the preview pane at startup, toggled with ctrl+o (default: false)
+	PreviewPosition string `json:"preview_position"` // Preview pane placement: "right" or "bottom" (default: "right")
+	PreviewCommand  string `json:"preview_command"`  // Command whose output fills the preview pane, like "man {1}" (default: "", the command's details)
 
 	// Display
 	Reverse bool   `json:"reverse"` // Reverse display order (default: false)
-	Mode    string `json:"mode"`    // Search mode: "exact" or "fuzzy" (default: "exact")
+	Mode    string `json:"mode"`    // Search mode: "exact", "fuzzy", "approx" or "regex" (default: "exact")
+	Case    string `json:"case"`    // Case sensitivity: "smart", "sensitive" or "insensitive" (default: "smart")
+	Sort    string `json:"sort"`    // Result order: "relevance", "frecency" or "duration" (default: "relevance")
+	Dedupe  string `json:"dedupe"`  // Repeated commands: "none", "most-recent" or "first" (default: "none")
+
+	// History
+	HistoryFile   string `json:"history_file"`    // History file(s) to read, separated by ':'; overrides $HISTFILE (default: shell's own file)
+	MaxLineLength int    `json:"max_line_length"` // Longest history line in bytes before long_lines applies (default: 65536)
+	LongLines     string `json:"long_lines"`      // Long line policy: "truncate" or "keep" (default: "truncate")
+	Cache         bool   `json:"cache"`           // Cache parsed history in ~/.cache/sheek (default: true)
+	Watch         bool   `json:"watch"`           // Add commands appended to history while open (default: true)
 
 	// Input
 	Limit       int    `json:"limit"`       // Input character limit (default: 128)
@@ -31,6 +61,9 @@ type Config struct {
 	// List
 	Title string `json:"title"` // List title (default: "Recent Commands")
 
+	// Frecency
+	Frecency FrecencyConfig `json:"frecency"` // Frecency sort weights
+
 	// Colors
 	Colors ColorConfig `json:"colors"` // Color configuration
 }
@@ -42,11 +75,29 @@ func DefaultConfig() *Config {
 		Height:        12,
 		Margin:        1,
 		ShowTimestamp: true,
+		ShowDuration:  false,
+
+		Preview:         false,
+		PreviewPosition: "right",
+		PreviewCommand:  "",
+
 		Reverse:       false,
 		Mode:          "exact",
+		Case:          "smart",
+		Sort:          "relevance",
+		Dedupe:        "none",
+		MaxLineLength: 64 * 1024,
+		LongLines:     "truncate",
+		Cache:         true,
+		Watch:         true,
 		Limit:         128,
 		Placeholder:   "Search History...",
 		Title:         "Recent Commands",
+		Frecency: FrecencyConfig{
+			Frequency:     1,
+			Recency:       5,
+			HalfLifeHours: 168,
+		},
 		Colors: ColorConfig{
 			Primary:    "#7D56F4",
 			Secondary:  "#04B575",
@@ -56,6 +107,13 @@ func DefaultConfig() *Config {
 			Selected:   "#3A3A5C",
 			Highlight:  "#FFD700",
 			Background: "#1A1A1A",
+			Error:      "#FF5F87",
+			Program:    "#5FAFFF",
+			Flag:       "#AF87FF",
+			String:     "#87D787",
+			Variable:   "#FFAF5F",
+			Operator:   "#FF87D7",
+			Comment:    "#808080",
 		},
 	}
 }
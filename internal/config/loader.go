@@ -111,11 +111,29 @@ func validateAndMergeDefaults(cfg Config) Config {
 	if cfg.Margin < 0 {
 		cfg.Margin = defaults.Margin
 	}
+	if cfg.MaxLineLength <= 0 {
+		cfg.MaxLineLength = defaults.MaxLineLength
+	}
 
 	// Validate and apply defaults for string fields
-	if cfg.Mode != "exact" && cfg.Mode != "fuzzy" {
+	if cfg.Mode != "exact" && cfg.Mode != "fuzzy" && cfg.Mode != "approx" && cfg.Mode != "regex" {
 		cfg.Mode = defaults.Mode
 	}
+	if cfg.Case != "smart" && cfg.Case != "sensitive" && cfg.Case != "insensitive" {
+		cfg.Case = defaults.Case
+	}
+	if cfg.Sort != "relevance" && cfg.Sort != "frecency" && cfg.Sort != "duration" {
+		cfg.Sort = defaults.Sort
+	}
+	if cfg.Dedupe != "none" && cfg.Dedupe != "most-recent" && cfg.Dedupe != "first" {
+		cfg.Dedupe = defaults.Dedupe
+	}
+	if cfg.PreviewPosition != "right" && cfg.PreviewPosition != "bottom" {
+		cfg.PreviewPosition = defaults.PreviewPosition
+	}
+	if cfg.LongLines != "truncate" && cfg.LongLines != "keep" {
+		cfg.LongLines = defaults.LongLines
+	}
 	if cfg.Placeholder == "" {
 		cfg.Placeholder = defaults.Placeholder
 	}
@@ -128,12 +146,30 @@ func validateAndMergeDefaults(cfg Config) Config {
 		cfg.Limit = defaults.Limit
 	}
 
+	// Validate frecency weights
+	cfg.Frecency = validateFrecency(cfg.Frecency, defaults.Frecency)
+
 	// Validate and merge color config
 	cfg.Colors = validateColors(cfg.Colors, defaults.Colors)
 
 	return cfg
 }
 
+// validateFrecency replaces negative weights and non-positive half-lives with defaults
+func validateFrecency(frecency, defaults FrecencyConfig) FrecencyConfig {
+	if frecency.Frequency < 0 {
+		frecency.Frequency = defaults.Frequency
+	}
+	if frecency.Recency < 0 {
+		frecency.Recency = defaults.Recency
+	}
+	if frecency.HalfLifeHours <= 0 {
+		frecency.HalfLifeHours = defaults.HalfLifeHours
+	}
+
+	return frecency
+}
+
 // validateColors validates color values and applies defaults for missing/invalid colors
 func validateColors(colors, defaults ColorConfig) ColorConfig {
 	if colors.Primary == "" {
@@ -160,6 +196,27 @@ func validateColors(colors, defaults ColorConfig) ColorConfig {
 	if colors.Background == "" {
 		colors.Background = defaults.Background
 	}
+	if colors.Error == "" {
+		colors.Error = defaults.Error
+	}
+	if colors.Program == "" {
+		colors.Program = defaults.Program
+	}
+	if colors.Flag == "" {
+		colors.Flag = defaults.Flag
+	}
+	if colors.String == "" {
+		colors.String = defaults.String
+	}
+	if colors.Variable == "" {
+		colors.Variable = defaults.Variable
+	}
+	if colors.Operator == "" {
+		colors.Operator = defaults.Operator
+	}
+	if colors.Comment == "" {
+		colors.Comment = defaults.Comment
+	}
 
 	return colors
 }
@@ -0,0 +1,239 @@
+package history
+
+import (
+	"cmp"
+	"context"
+	"math/bits"
+	"slices"
+	"unicode/utf8"
+)
+
+// maxTypos returns how many edits a query token of n runes may be away from the text.
+// Short tokens have to match exactly, or almost any command would match them.
+func maxTypos(n int) int {
+	switch {
+	case n < 3:
+		return 0
+	case n < 6:
+		return 1
+	}
+	return 2
+}
+
+// approxToken is one whitespace separated token of an approximate query
+type approxToken struct {
+	sensitive bool
+	runes     []rune // Case folded unless sensitive
+	maxDist   int
+	mask      uint64 // charMask of the ASCII bytes of the case folded token, see asciiMask
+}
+
+// approxQuery is a query for approximate matching. Every token must be within its
+// edit distance of some part of the command.
+type approxQuery []approxToken
+
+// parseApproxQuery splits input into tokens on spaces that aren't escaped with a backslash
+func parseApproxQuery(input string, caseMode CaseMode) approxQuery {
+	var q approxQuery
+	for _, token := range splitQuery(input) {
+		sensitive := caseMode.sensitive(token)
+		if !sensitive {
+			token = foldString(token)
+		}
+		runes := []rune(token)
+		q = append(q, approxToken{
+			sensitive: sensitive,
+			runes:     runes,
+			maxDist:   maxTypos(len(runes)),
+			mask:      asciiMask(foldString(token)),
+		})
+	}
+	return q
+}
+
+// possible reports whether a command whose folded text has the given charMask may match q.
+// Every rune of a token that's missing from the command takes at least one edit.
+func (q approxQuery) possible(mask uint64) bool {
+	for _, token := range q {
+		if bits.OnesCount64(token.mask&^mask) > token.maxDist {
+			return false
+		}
+	}
+	return true
+}
+
+// asciiMask is the charMask of the ASCII bytes of s. Every byte of a multibyte rune sets
+// its own bit, so a single missing rune like the é of "café" would count as two edits.
+func asciiMask(s string) uint64 {
+	var mask uint64
+	for i := 0; i < len(s); i++ {
+		if s[i] < utf8.RuneSelf {
+			mask |= 1 << (s[i] & 63)
+		}
+	}
+	return mask
+}
+
+// approxMatcher holds the scratch buffers of approximate matching so they can be reused
+// across commands. An approxMatcher must not be used by more than one goroutine at a time.
+type approxMatcher struct {
+	text, folded []rune
+	reversed     []rune // Reversed token followed by the reversed text, see bestStart
+	cols         [3][]int
+}
+
+// match reports whether every token of q matches text and returns the summed edit
+// distance and the sorted rune positions of the parts of text the tokens matched
+func (am *approxMatcher) match(q approxQuery, text string) (int, []int, bool) {
+	am.text, am.folded = am.text[:0], am.folded[:0]
+	for _, r := range text {
+		am.text = append(am.text, r)
+		am.folded = append(am.folded, foldRune(r))
+	}
+
+	total := 0
+	var positions []int
+	for _, token := range q {
+		subject := am.folded
+		if token.sensitive {
+			subject = am.text
+		}
+		dist, end := am.bestEnd(subject, token.runes, token.maxDist)
+		if dist > token.maxDist {
+			return 0, nil, false
+		}
+		total += dist
+		for i := am.bestStart(subject[:end], token.runes, dist); i < end; i++ {
+			positions = append(positions, i)
+		}
+	}
+
+	slices.Sort(positions)
+	return total, slices.Compact(positions), true
+}
+
+// bestEnd finds the part of text closest to token by optimal string alignment distance,
+// in which swapping two adjacent runes is a single edit. It returns the distance and
+// the rune offset just past the earliest such part, or 0 if it's more than maxDist.
+func (am *approxMatcher) bestEnd(text, token []rune, maxDist int) (int, int) {
+	m := len(token)
+	prev2, prev, cur := am.column(0, m), am.column(1, m), am.column(2, m)
+	for i := range prev {
+		prev[i] = i
+	}
+
+	best, end := prev[m], 0
+	for j := 1; j <= len(text) && best > 0; j++ {
+		cur[0] = 0 // A match may start anywhere
+		for i := 1; i <= m; i++ {
+			cur[i] = editStep(prev2, prev, cur, token, text, i, j)
+		}
+		if cur[m] < best || cur[m] == best && end == j-1 {
+			// Extend a best part while the next rune costs nothing, so "kubeclt"
+			// covers all of "kubectl" rather than stopping at "kubect"
+			best, end = cur[m], j
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	if best > maxDist {
+		return best, 0
+	}
+	return best, end
+}
+
+// bestStart returns the rune offset where the shortest part of text ending at its end
+// is dist edits from token, by running the alignment backwards from the end
+func (am *approxMatcher) bestStart(text, token []rune, dist int) int {
+	am.reversed = append(append(am.reversed[:0], token...), text...)
+	reversedToken, reversedText := am.reversed[:len(token)], am.reversed[len(token):]
+	slices.Reverse(reversedToken)
+	slices.Reverse(reversedText)
+
+	m := len(token)
+	prev2, prev, cur := am.column(0, m), am.column(1, m), am.column(2, m)
+	for i := range prev {
+		prev[i] = i
+	}
+	for j := 1; j <= len(reversedText); j++ {
+		cur[0] = j // The match has to end where bestEnd found it
+		for i := 1; i <= m; i++ {
+			cur[i] = editStep(prev2, prev, cur, reversedToken, reversedText, i, j)
+		}
+		if cur[m] <= dist {
+			return len(text) - j
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return 0
+}
+
+// editStep computes cell i of the column for text rune j from the two previous columns
+func editStep(prev2, prev, cur []int, token, text []rune, i, j int) int {
+	cost := 1
+	if token[i-1] == text[j-1] {
+		cost = 0
+	}
+	d := min(prev[i]+1, cur[i-1]+1, prev[i-1]+cost)
+	if i > 1 && j > 1 && token[i-1] == text[j-2] && token[i-2] == text[j-1] {
+		d = min(d, prev2[i-2]+1)
+	}
+	return d
+}
+
+// column returns scratch column k resized to hold m+1 cells
+func (am *approxMatcher) column(k, m int) []int {
+	return resize(&am.cols[k], m+1)
+}
+
+// approxMatch is a command matched approximately, with the summed edit distance of the query's tokens
+type approxMatch struct {
+	command   Command
+	distance  int
+	positions []int // Rune positions of the parts of the command the tokens matched
+}
+
+// SearchApproximate returns the commands that every token of input matches with a few
+// typos, like "dokcer" for "docker", closest first. Tokens of 3 to 5 runes may be one
+// edit away and longer ones two, where an edit inserts, deletes, replaces or swaps
+// adjacent runes. Case is treated like in CaseSmart mode.
+func SearchApproximate(commands []Command, input string) []FuzzyMatchResult {
+	results, _ := SearchApproximateContext(context.Background(), commands, input, CaseSmart)
+	return results
+}
+
+// SearchApproximateContext is like SearchApproximate with an explicit CaseMode,
+// but gives up with ctx's error once ctx is done
+func SearchApproximateContext(ctx context.Context, commands []Command, input string, caseMode CaseMode) ([]FuzzyMatchResult, error) {
+	query := parseApproxQuery(input, caseMode)
+	if len(query) == 0 {
+		return unmatchedResults(commands), nil
+	}
+
+	var matches []approxMatch
+	var am approxMatcher
+	for i, cmd := range commands {
+		if i%searchCheckInterval == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if dist, positions, ok := am.match(query, cmd.Text); ok {
+			matches = append(matches, approxMatch{command: cmd, distance: dist, positions: positions})
+		}
+	}
+	return approxResults(matches), nil
+}
+
+// approxResults sorts matches by distance, then by index, and converts them to results
+func approxResults(matches []approxMatch) []FuzzyMatchResult {
+	slices.SortFunc(matches, func(a, b approxMatch) int {
+		if a.distance != b.distance {
+			return cmp.Compare(a.distance, b.distance)
+		}
+		return cmp.Compare(a.command.Index, b.command.Index)
+	})
+
+	results := make([]FuzzyMatchResult, len(matches))
+	for i, match := range matches {
+		results[i] = FuzzyMatchResult{Command: match.command, Positions: match.positions}
+	}
+	return results
+}
@@ -0,0 +1,105 @@
+package history
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestSearchApproximate(t *testing.T) {
+	cmds := []Command{
+		{Index: 1, Text: "docker ps -a"},
+		{Index: 2, Text: "kubectl get pods"},
+		{Index: 3, Text: "git checkout main"},
+		{Index: 4, Text: "dokcer ps"},
+		{Index: 5, Text: "ls -la"},
+	}
+
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{"dokcer", []int{4, 1}},
+		{"kubeclt", []int{2}},
+		{"kubeclt pdos", []int{2}},
+		{"gti chekcout", []int{3}},
+		{"ls", []int{5}},
+		{"sl", nil},
+		{"podman", nil},
+		{"", []int{1, 2, 3, 4, 5}},
+	}
+
+	for _, tt := range tests {
+		var got []int
+		for _, result := range SearchApproximate(cmds, tt.query) {
+			got = append(got, result.Command.Index)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("SearchApproximate(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSearchApproximatePositions(t *testing.T) {
+	tests := []struct {
+		text  string
+		query string
+		want  []int
+	}{
+		{"docker ps", "dokcer", []int{0, 1, 2, 3, 4, 5}},
+		{"sudo kubectl apply", "kubeclt", []int{5, 6, 7, 8, 9, 10, 11}},
+		{"git checkout", "chekout", []int{4, 5, 6, 7, 8, 9, 10, 11}},
+		{"git commit", "comit git", []int{0, 1, 2, 4, 5, 6, 7, 8, 9}},
+		{"échoer", "ÉCHO", nil},
+		{"ÉCHOER", "écho", []int{0, 1, 2, 3}},
+	}
+
+	for _, tt := range tests {
+		results := SearchApproximate([]Command{{Text: tt.text}}, tt.query)
+		var got []int
+		if len(results) > 0 {
+			got = results[0].Positions
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("SearchApproximate(%q) positions in %q = %v, want %v", tt.query, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestIndexSearchApproximate(t *testing.T) {
+	cmds := benchmarkCommands(2000)
+	ix := NewIndex(cmds)
+	for _, query := range []string{"dokcer", "gti stauts", "kubeclt", "ls"} {
+		got, err := ix.SearchApproximate(context.Background(), query, CaseSmart)
+		if err != nil {
+			t.Fatalf("Index.SearchApproximate(%q) error: %v", query, err)
+		}
+		if want := SearchApproximate(cmds, query); !reflect.DeepEqual(got, want) {
+			t.Errorf("Index.SearchApproximate(%q) returned %d results, want %d", query, len(got), len(want))
+		}
+	}
+}
+
+func TestIndexSearchApproximateNonASCII(t *testing.T) {
+	cmds := []Command{
+		{Index: 1, Text: "echo cafe"},
+		{Index: 2, Text: "echo café crème"},
+		{Index: 3, Text: "python naive_bayes.py"},
+		{Index: 4, Text: "cat 日本語.txt"},
+	}
+	ix := NewIndex(cmds)
+	for _, query := range []string{"café", "cafe", "creme", "naïve", "日本人", "日本語"} {
+		got, err := ix.SearchApproximate(context.Background(), query, CaseSmart)
+		if err != nil {
+			t.Fatalf("Index.SearchApproximate(%q) error: %v", query, err)
+		}
+		want := SearchApproximate(cmds, query)
+		if len(want) == 0 {
+			t.Fatalf("SearchApproximate(%q) found nothing to compare with", query)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Index.SearchApproximate(%q) = %v, want %v", query, got, want)
+		}
+	}
+}
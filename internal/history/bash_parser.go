@@ -0,0 +1,54 @@
+package history
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// bashTimestampLine matches the "#<epoch>" lines bash writes when HISTTIMEFORMAT is set
+var bashTimestampLine = regexp.MustCompile(`^#[0-9]+$`)
+
+// bashParser parses bash history with or without HISTTIMEFORMAT timestamps
+type bashParser struct {
+	commandBuilder
+	timestamped bool // a "#<epoch>" line has been seen
+}
+
+// ParseBashHistory converts raw bash history lines into commands.
+// Without timestamps every line is a command. When a "#<epoch>" line is present,
+// all lines up to the next timestamp belong to the same (multi-line) command.
+func ParseBashHistory(rawLines []string) []Command {
+	return ParseHistory(ShellBash, rawLines)
+}
+
+func (p *bashParser) parseLine(line string) bool {
+	if bashTimestampLine.MatchString(line) {
+		p.finish()
+		p.timestamp = parseBashTimestamp(line)
+		p.timestamped = true
+		return true
+	}
+
+	if !p.timestamped {
+		// Untimestamped lines are complete commands on their own
+		p.finish()
+		p.current.WriteString(line)
+		return true
+	}
+
+	if p.current.Len() > 0 {
+		p.current.WriteString("\n")
+	}
+	p.current.WriteString(line)
+	return false
+}
+
+func parseBashTimestamp(line string) time.Time {
+	epoch, err := strconv.ParseInt(strings.TrimPrefix(line, "#"), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(epoch, 0)
+}
@@ -0,0 +1,295 @@
+package history
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	cacheAppName = "sheek"
+	cacheMagic   = "SHEEKHC"
+	// cacheVersion must be bumped whenever parsing or the file layout changes
+	cacheVersion = 1
+	// cacheTailSize is how many bytes before the cached end are checksummed to detect rewrites
+	cacheTailSize = 4096
+)
+
+// errCacheMismatch reports a cache file that doesn't belong to the requested history file
+var errCacheMismatch = errors.New("history cache does not match")
+
+// DefaultCacheDir returns the directory parsed history is cached in (~/.cache/sheek)
+func DefaultCacheDir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get cache directory: %w", err)
+	}
+	return filepath.Join(cacheDir, cacheAppName), nil
+}
+
+// historyCache is the persisted parse state of one history file
+type historyCache struct {
+	Path     string
+	Shell    Shell
+	Options  LoadOptions
+	Inode    uint64
+	Size     int64 // Bytes of the file that were parsed
+	ModTime  int64 // Modification time in Unix nanoseconds when parsed
+	TailSum  uint32
+	Resume   resumePoint
+	Commands []Command
+}
+
+// loadHistoryFileCached parses path using the cache in opts.CacheDir.
+// Unchanged files are served straight from the cache, files that only grew are
+// parsed from the last cached command onwards, and anything else is reparsed.
+// Cache failures are never fatal: they fall back to a full parse.
+func loadHistoryFileCached(path string, shell Shell, opts LoadOptions) ([]Command, fileState, error) {
+	if absPath, err := filepath.Abs(path); err == nil {
+		path = absPath
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fileState{}, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fileState{}, err
+	}
+
+	cachePath := cacheFilePath(opts.CacheDir, path)
+	cached, err := readHistoryCache(cachePath)
+	if err == nil && !cached.matches(path, shell, opts, info) {
+		err = errCacheMismatch
+	}
+	if err == nil {
+		if cached.Size == info.Size() && cached.ModTime == info.ModTime().UnixNano() {
+			return cached.Commands, cached.state(), nil
+		}
+		if sum, sumErr := tailChecksum(file, cached.Size); sumErr != nil || sum != cached.TailSum {
+			err = errCacheMismatch
+		}
+	}
+
+	from := resumePoint{}
+	var commands []Command
+	if err == nil {
+		// The file only grew: keep every command before the last one and parse from there
+		from = cached.Resume
+		commands = cached.Commands[:from.Emitted]
+	}
+
+	state, err := parseFileFrom(file, info, shell, opts, from, func(cmd Command) {
+		commands = append(commands, cmd)
+	})
+	if err != nil {
+		return nil, fileState{}, err
+	}
+
+	sum, err := tailChecksum(file, state.Size)
+	if err != nil {
+		return commands, state, nil
+	}
+
+	// Saving is best effort; a read-only cache directory shouldn't stop sheek
+	_ = writeHistoryCache(cachePath, &historyCache{
+		Path:     path,
+		Shell:    shell,
+		Options:  opts,
+		Inode:    state.Inode,
+		Size:     state.Size,
+		ModTime:  info.ModTime().UnixNano(),
+		TailSum:  sum,
+		Resume:   state.Resume,
+		Commands: commands,
+	})
+	return commands, state, nil
+}
+
+// state returns how far the cached file was parsed
+func (c *historyCache) state() fileState {
+	return fileState{Inode: c.Inode, Size: c.Size, Resume: c.Resume}
+}
+
+// matches reports whether the cache was built from the same file with the same settings
+func (c *historyCache) matches(path string, shell Shell, opts LoadOptions, info os.FileInfo) bool {
+	return c.Path == path &&
+		c.Shell == shell &&
+		c.Options.MaxLineLength == opts.MaxLineLength &&
+		c.Options.LongLinePolicy == opts.LongLinePolicy &&
+		c.Inode == fileInode(info) &&
+		c.Size <= info.Size() &&
+		c.Resume.Emitted <= len(c.Commands)
+}
+
+// cacheFilePath names the cache file for a history file after a hash of its path
+func cacheFilePath(cacheDir, historyPath string) string {
+	sum := sha256.Sum256([]byte(historyPath))
+	return filepath.Join(cacheDir, "history-"+hex.EncodeToString(sum[:8])+".cache")
+}
+
+// tailChecksum checksums up to cacheTailSize bytes just before end
+func tailChecksum(file *os.File, end int64) (uint32, error) {
+	start := max(0, end-cacheTailSize)
+	buf := make([]byte, end-start)
+	if _, err := file.ReadAt(buf, start); err != nil {
+		return 0, err
+	}
+	return crc32.ChecksumIEEE(buf), nil
+}
+
+// writeHistoryCache stores the cache atomically so concurrent sheek instances never see a partial file
+func writeHistoryCache(cachePath string, cache *historyCache) error {
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(cachePath), filepath.Base(cachePath)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	encodeHistoryCache(w, cache)
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), cachePath)
+}
+
+// readHistoryCache loads a cache file written by writeHistoryCache
+func readHistoryCache(cachePath string) (*historyCache, error) {
+	file, err := os.Open(cachePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return decodeHistoryCache(bufio.NewReader(file), info.Size())
+}
+
+// encodeHistoryCache writes the cache in a compact varint layout that decodes
+// much faster than gob for hundreds of thousands of commands.
+func encodeHistoryCache(w *bufio.Writer, c *historyCache) {
+	var buf [binary.MaxVarintLen64]byte
+	putInt := func(v int64) {
+		w.Write(buf[:binary.PutVarint(buf[:], v)])
+	}
+	putString := func(s string) {
+		putInt(int64(len(s)))
+		w.WriteString(s)
+	}
+
+	w.WriteString(cacheMagic)
+	putInt(cacheVersion)
+	putString(c.Path)
+	putString(string(c.Shell))
+	putInt(int64(c.Options.MaxLineLength))
+	putString(string(c.Options.LongLinePolicy))
+	putInt(int64(c.Inode))
+	putInt(c.Size)
+	putInt(c.ModTime)
+	putInt(int64(c.TailSum))
+	putInt(c.Resume.Offset)
+	putInt(int64(c.Resume.Emitted))
+
+	putInt(int64(len(c.Commands)))
+	for _, cmd := range c.Commands {
+		putString(cmd.Text)
+		var ts int64
+		if !cmd.Timestamp.IsZero() {
+			ts = cmd.Timestamp.Unix()
+		}
+		putInt(ts)
+		putInt(int64(cmd.Duration / time.Second))
+	}
+}
+
+// decodeHistoryCache is the inverse of encodeHistoryCache. size is the length of the
+// encoded cache, which lengths and counts read from a corrupt cache can't exceed.
+func decodeHistoryCache(r *bufio.Reader, size int64) (*historyCache, error) {
+	magic := make([]byte, len(cacheMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != cacheMagic {
+		return nil, errCacheMismatch
+	}
+
+	var decodeErr error
+	getInt := func() int64 {
+		if decodeErr != nil {
+			return 0
+		}
+		v, err := binary.ReadVarint(r)
+		if err != nil {
+			decodeErr = err
+		}
+		return v
+	}
+	getString := func() string {
+		n := getInt()
+		if decodeErr != nil || n < 0 || n > size {
+			decodeErr = errCacheMismatch
+			return ""
+		}
+		b := make([]byte, n)
+		if _, err := io.ReadFull(r, b); err != nil {
+			decodeErr = err
+			return ""
+		}
+		return string(b)
+	}
+
+	if getInt() != cacheVersion {
+		return nil, errCacheMismatch
+	}
+
+	c := &historyCache{}
+	c.Path = getString()
+	c.Shell = Shell(getString())
+	c.Options.MaxLineLength = int(getInt())
+	c.Options.LongLinePolicy = LongLinePolicy(getString())
+	c.Inode = uint64(getInt())
+	c.Size = getInt()
+	c.ModTime = getInt()
+	c.TailSum = uint32(getInt())
+	c.Resume.Offset = getInt()
+	c.Resume.Emitted = int(getInt())
+
+	// Every command takes at least a byte for each of its text length, timestamp and duration
+	count := getInt()
+	if decodeErr != nil || count < 0 || count > size/3 || c.Resume.Emitted < 0 || int64(c.Resume.Emitted) > count {
+		return nil, errCacheMismatch
+	}
+	c.Commands = make([]Command, 0, count)
+	for i := int64(0); i < count; i++ {
+		cmd := Command{Index: int(i) + 1, Text: getString()}
+		if ts := getInt(); ts != 0 {
+			cmd.Timestamp = time.Unix(ts, 0)
+		}
+		cmd.Duration = time.Duration(getInt()) * time.Second
+		if decodeErr != nil {
+			return nil, decodeErr
+		}
+		c.Commands = append(c.Commands, cmd)
+	}
+	return c, nil
+}
@@ -0,0 +1,176 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoadHistoryFileCached(t *testing.T) {
+	dir := t.TempDir()
+	historyFile := filepath.Join(dir, "zsh_history")
+	opts := DefaultLoadOptions()
+	opts.CacheDir = filepath.Join(dir, "cache")
+
+	load := func() []Command {
+		t.Helper()
+		cmds, _, err := loadHistoryFile(historyFile, ShellZsh, opts)
+		if err != nil {
+			t.Fatalf("loadHistoryFile error: %v", err)
+		}
+		return cmds
+	}
+	fullParse := func() []Command {
+		t.Helper()
+		uncached := opts
+		uncached.CacheDir = ""
+		cmds, _, err := loadHistoryFile(historyFile, ShellZsh, uncached)
+		if err != nil {
+			t.Fatalf("loadHistoryFile error: %v", err)
+		}
+		return cmds
+	}
+
+	writeFile(t, historyFile, ": 1700000000:0;first\n: 1700000100:3;second\\\n")
+	first := load()
+	if _, err := os.Stat(cacheFilePath(opts.CacheDir, historyFile)); err != nil {
+		t.Fatalf("cache file not written: %v", err)
+	}
+	if !reflect.DeepEqual(first, fullParse()) {
+		t.Errorf("initial load = %v, want %v", first, fullParse())
+	}
+
+	t.Run("unchanged file is served from cache", func(t *testing.T) {
+		if got := load(); !reflect.DeepEqual(got, first) {
+			t.Errorf("cached load = %v, want %v", got, first)
+		}
+	})
+
+	t.Run("appended tail continues the last command", func(t *testing.T) {
+		appendFile(t, historyFile, "continued\n: 1700000200:0;third\n")
+		got := load()
+		assertCommands(t, got, []string{"first", "second\ncontinued", "third"}, []int64{1700000000, 1700000100, 1700000200})
+		if !reflect.DeepEqual(got, fullParse()) {
+			t.Errorf("incremental load = %v, want %v", got, fullParse())
+		}
+	})
+
+	t.Run("rewritten file is reparsed", func(t *testing.T) {
+		writeFile(t, historyFile, ": 1700000500:0;replaced entirely with something longer\n: 1700000600:0;and more\n")
+		got := load()
+		assertCommands(t, got,
+			[]string{"replaced entirely with something longer", "and more"},
+			[]int64{1700000500, 1700000600},
+		)
+	})
+}
+
+func TestLoadHistoryFileCachedParsesOnlyTail(t *testing.T) {
+	dir := t.TempDir()
+	historyFile := filepath.Join(dir, "zsh_history")
+	opts := DefaultLoadOptions()
+	opts.CacheDir = filepath.Join(dir, "cache")
+
+	// Keep the first command outside the checksummed tail window
+	content := ": 1700000000:0;first\n" + strings.Repeat(": 1700000001:0;"+strings.Repeat("x", 80)+"\n", cacheTailSize/80)
+	writeFile(t, historyFile, content)
+	if _, _, err := loadHistoryFile(historyFile, ShellZsh, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	// Change the head in place and append: the cached head must be reused as is
+	writeFile(t, historyFile, strings.Replace(content, "first", "FIRST", 1)+": 1700000200:0;last\n")
+	got, _, err := loadHistoryFile(historyFile, ShellZsh, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0].Text != "first" {
+		t.Errorf("first command = %q, want the cached %q", got[0].Text, "first")
+	}
+	if last := got[len(got)-1]; last.Text != "last" || last.Index != len(got) {
+		t.Errorf("last command = %+v, want Text %q and Index %d", last, "last", len(got))
+	}
+}
+
+func TestDecodeCorruptHistoryCache(t *testing.T) {
+	encode := func(c *historyCache) []byte {
+		var buf bytes.Buffer
+		w := bufio.NewWriter(&buf)
+		encodeHistoryCache(w, c)
+		w.Flush()
+		return buf.Bytes()
+	}
+	varint := func(v int64) []byte {
+		return binary.AppendVarint(nil, v)
+	}
+
+	valid := encode(&historyCache{Path: "/h", Shell: ShellZsh, Resume: resumePoint{Emitted: 1}, Commands: []Command{{Text: "ls"}}})
+	empty := encode(&historyCache{Path: "/h", Shell: ShellZsh})
+	header := empty[:len(empty)-1] // Without the command count of 0
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"huge command count", append(slices.Clone(header), varint(1<<62)...)},
+		{"count past the payload", append(slices.Clone(header), varint(100)...)},
+		{"negative command count", append(slices.Clone(header), varint(-1)...)},
+		{"huge text length", append(append(slices.Clone(header), varint(1)...), varint(1<<62)...)},
+		{"resume past the commands", encode(&historyCache{Resume: resumePoint{Emitted: 5}, Commands: []Command{{Text: "ls"}}})},
+	}
+	for n := range valid {
+		tests = append(tests, struct {
+			name string
+			data []byte
+		}{fmt.Sprintf("truncated to %d bytes", n), valid[:n]})
+	}
+
+	for _, tt := range tests {
+		if _, err := decodeHistoryCache(bufio.NewReader(bytes.NewReader(tt.data)), int64(len(tt.data))); err == nil {
+			t.Errorf("%s: decodeHistoryCache succeeded, want an error", tt.name)
+		}
+	}
+	if _, err := decodeHistoryCache(bufio.NewReader(bytes.NewReader(valid)), int64(len(valid))); err != nil {
+		t.Errorf("decodeHistoryCache of a valid cache error: %v", err)
+	}
+}
+
+func TestCorruptHistoryCacheFallsBackToParse(t *testing.T) {
+	dir := t.TempDir()
+	historyFile := filepath.Join(dir, "zsh_history")
+	opts := DefaultLoadOptions()
+	opts.CacheDir = filepath.Join(dir, "cache")
+	writeFile(t, historyFile, ": 1700000000:0;first\n")
+
+	cachePath := cacheFilePath(opts.CacheDir, historyFile)
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	corrupt := append([]byte(cacheMagic), binary.AppendVarint(nil, cacheVersion)...)
+	writeFile(t, cachePath, string(append(corrupt, binary.AppendVarint(nil, 1<<62)...)))
+
+	got, _, err := loadHistoryFile(historyFile, ShellZsh, opts)
+	if err != nil {
+		t.Fatalf("loadHistoryFile error: %v", err)
+	}
+	assertCommands(t, got, []string{"first"}, []int64{1700000000})
+}
+
+func appendFile(t *testing.T, path, content string) {
+	t.Helper()
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+}
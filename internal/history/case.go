@@ -0,0 +1,87 @@
+package history
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"unicode"
+)
+
+// CaseMode determines whether searches tell upper and lower case apart
+type CaseMode string
+
+const (
+	// CaseSmart ignores case unless the query contains an uppercase letter
+	CaseSmart CaseMode = "smart"
+	// CaseSensitive always matches case exactly
+	CaseSensitive CaseMode = "sensitive"
+	// CaseInsensitive always ignores case
+	CaseInsensitive CaseMode = "insensitive"
+)
+
+// caseModes lists the case modes in the order they are cycled through
+var caseModes = []CaseMode{CaseSmart, CaseSensitive, CaseInsensitive}
+
+// ParseCaseMode converts a config value into a CaseMode, reporting whether it is known
+func ParseCaseMode(value string) (CaseMode, bool) {
+	for _, mode := range caseModes {
+		if string(mode) == value {
+			return mode, true
+		}
+	}
+	return CaseSmart, false
+}
+
+// Next returns the case mode that follows c
+func (c CaseMode) Next() CaseMode {
+	for i, mode := range caseModes {
+		if mode == c {
+			return caseModes[(i+1)%len(caseModes)]
+		}
+	}
+	return CaseSmart
+}
+
+// sensitive reports whether text is matched case sensitively in mode c
+func (c CaseMode) sensitive(text string) bool {
+	switch c {
+	case CaseSensitive:
+		return true
+	case CaseInsensitive:
+		return false
+	}
+	return strings.IndexFunc(text, unicode.IsUpper) >= 0
+}
+
+// CompileRegex compiles a pattern for SearchRegex using Go's regexp syntax.
+// In CaseSmart mode the pattern ignores case unless one of its literal characters is
+// uppercase, so escapes like \S or \D don't make it case sensitive.
+func CompileRegex(pattern string, caseMode CaseMode) (*regexp.Regexp, error) {
+	sensitive := caseMode == CaseSensitive
+	if caseMode == CaseSmart {
+		if parsed, err := syntax.Parse(pattern, syntax.Perl); err == nil {
+			sensitive = hasUpperLiteral(parsed)
+		}
+	}
+	if sensitive {
+		return regexp.Compile(pattern)
+	}
+	return regexp.Compile("(?i)" + pattern)
+}
+
+// hasUpperLiteral reports whether re matches an uppercase literal character case sensitively
+func hasUpperLiteral(re *syntax.Regexp) bool {
+	if re.Op == syntax.OpLiteral && re.Flags&syntax.FoldCase == 0 {
+		for _, r := range re.Rune {
+			if unicode.IsUpper(r) {
+				return true
+			}
+		}
+	}
+	for _, sub := range re.Sub {
+		if hasUpperLiteral(sub) {
+			return true
+		}
+	}
+	return false
+}
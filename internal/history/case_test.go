@@ -0,0 +1,89 @@
+package history
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestCaseModeNext(t *testing.T) {
+	mode := CaseSmart
+	for _, want := range []CaseMode{CaseSensitive, CaseInsensitive, CaseSmart} {
+		mode = mode.Next()
+		if mode != want {
+			t.Errorf("Next() = %q, want %q", mode, want)
+		}
+	}
+}
+
+func TestSearchCaseModes(t *testing.T) {
+	cmds := []Command{
+		{Index: 1, Text: "ls -d /tmp"},
+		{Index: 2, Text: "ls -D /tmp"},
+		{Index: 3, Text: "git branch -D old"},
+	}
+
+	tests := []struct {
+		query    string
+		caseMode CaseMode
+		exact    []int
+		fuzzy    []int
+	}{
+		{"-d", CaseSmart, []int{1, 2, 3}, []int{1, 2, 3}},
+		{"-D", CaseSmart, []int{2, 3}, []int{2, 3}},
+		{"-D", CaseInsensitive, []int{1, 2, 3}, []int{1, 2, 3}},
+		{"-d", CaseSensitive, []int{1}, []int{1, 3}},
+		{"ls -D", CaseSmart, []int{2}, []int{2}},
+		{"gbD", CaseSmart, nil, []int{3}},
+		{"GB", CaseSensitive, nil, nil},
+	}
+
+	ctx := context.Background()
+	for _, tt := range tests {
+		exact, _ := SearchExactContext(ctx, cmds, tt.query, tt.caseMode)
+		var got []int
+		for _, cmd := range exact {
+			got = append(got, cmd.Index)
+		}
+		if !slices.Equal(got, tt.exact) {
+			t.Errorf("SearchExactContext(%q, %s) = %v, want %v", tt.query, tt.caseMode, got, tt.exact)
+		}
+
+		fuzzy, _ := SearchFuzzyWithPositionsContext(ctx, cmds, tt.query, tt.caseMode)
+		got = nil
+		for _, result := range fuzzy {
+			got = append(got, result.Command.Index)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tt.fuzzy) {
+			t.Errorf("SearchFuzzyWithPositionsContext(%q, %s) = %v, want %v", tt.query, tt.caseMode, got, tt.fuzzy)
+		}
+	}
+}
+
+func TestCompileRegexCaseModes(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		caseMode CaseMode
+		text     string
+		want     bool
+	}{
+		{"-d", CaseSmart, "ls -D", true},
+		{"-D", CaseSmart, "ls -d", false},
+		{`\S+ -d`, CaseSmart, "LS -D", true},
+		{`\D-d`, CaseSmart, "ls -D", true},
+		{"[a-z]+", CaseSensitive, "LS", false},
+		{"-D", CaseInsensitive, "ls -d", true},
+		{"(?i)-D", CaseSmart, "ls -d", true},
+	}
+
+	for _, tt := range tests {
+		re, err := CompileRegex(tt.pattern, tt.caseMode)
+		if err != nil {
+			t.Fatalf("CompileRegex(%q, %s) error = %v", tt.pattern, tt.caseMode, err)
+		}
+		if got := re.MatchString(tt.text); got != tt.want {
+			t.Errorf("CompileRegex(%q, %s).MatchString(%q) = %v, want %v", tt.pattern, tt.caseMode, tt.text, got, tt.want)
+		}
+	}
+}
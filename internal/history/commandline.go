@@ -0,0 +1,301 @@
+package history
+
+import (
+	"path"
+	"strings"
+)
+
+// Word is a shell word of a command line with its quotes and escapes removed
+type Word struct {
+	Text  string
+	Start int // Byte offset of the word in the command text, quotes included
+	End   int
+}
+
+// SimpleCommand is one command of a command line, like each side of "make && make install"
+// or each stage of a pipeline
+type SimpleCommand struct {
+	Program    Word   // First word after any VAR=value assignments
+	Subcommand Word   // First argument that isn't an option, like "compose" of "docker compose up"; empty if none
+	Args       []Word // Every word after the program but redirections, the subcommand included
+	Piped      bool   // Reads the output of the previous command through a pipe
+}
+
+// ParseCommandLine splits a command line into its simple commands.
+// It follows the shell's quoting closely enough to find words and the control
+// operators | |& || && ; & between commands, but doesn't expand anything.
+// Redirections like "2>&1" or "> out" are left out of the words of a command.
+func ParseCommandLine(text string) []SimpleCommand {
+	var commands []SimpleCommand
+	var words []Word
+	piped, redirectTarget := false, false
+	endCommand := func(nextPiped bool) {
+		if cmd, ok := newSimpleCommand(words, piped); ok {
+			commands = append(commands, cmd)
+		}
+		words, piped, redirectTarget = nil, nextPiped, false
+	}
+
+	for _, token := range scanCommandLine(text) {
+		switch token.kind {
+		case wordToken:
+			n, fd := redirectionLength(text[token.start:token.end])
+			switch {
+			case redirectTarget:
+				redirectTarget = false
+			case n > 0:
+				redirectTarget = n == token.end-token.start && !fd // Like "> out" rather than ">out" or "2>&1"
+			default:
+				words = append(words, Word{Text: token.text, Start: token.start, End: token.end})
+			}
+		case operatorToken:
+			op := text[token.start:token.end]
+			endCommand(op == "|" || op == "|&") // |& pipes stderr too
+		}
+	}
+	endCommand(false)
+	return commands
+}
+
+// tokenKind is the kind of a shellToken
+type tokenKind int
+
+const (
+	wordToken     tokenKind = iota
+	operatorToken           // Control operator | |& || && ; & or a newline
+	commentToken            // Comment to the end of the line
+)
+
+// shellToken is a word, control operator or comment of a command line, in byte offsets
+type shellToken struct {
+	kind       tokenKind
+	start, end int
+	text       string     // Unquoted text of a word
+	parts      []wordPart // Quoted strings and expansions of a word, in order
+}
+
+// wordPart is a quoted string, quotes included, or an expansion like $HOME or $(date)
+// inside a word. Expansions inside double quotes follow the string they're part of.
+type wordPart struct {
+	kind       SyntaxKind // SyntaxString or SyntaxVariable
+	start, end int
+}
+
+// scanCommandLine splits a command line into its words, control operators and comments.
+// Redirections are left in the words, like "2>&1" or ">" and "out" of "> out".
+func scanCommandLine(text string) []shellToken {
+	var tokens []shellToken
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '\n' || c == ';':
+			tokens = append(tokens, shellToken{kind: operatorToken, start: i, end: i + 1})
+			i++
+		case (c == '|' || c == '&') && !isRedirection(text, i):
+			n := 1
+			if i+1 < len(text) && (text[i+1] == c || c == '|' && text[i+1] == '&') {
+				n = 2 // || && |&
+			}
+			tokens = append(tokens, shellToken{kind: operatorToken, start: i, end: i + n})
+			i += n
+		case c == '#':
+			// A comment runs to the end of the line
+			end := strings.IndexByte(text[i:], '\n')
+			if end < 0 {
+				end = len(text) - i
+			}
+			tokens = append(tokens, shellToken{kind: commentToken, start: i, end: i + end})
+			i += end
+		default:
+			token := scanWord(text, i)
+			tokens = append(tokens, token)
+			i = token.end
+		}
+	}
+	return tokens
+}
+
+// scanWord reads the word starting at text[i], removing its quotes and escapes
+func scanWord(text string, i int) shellToken {
+	token := shellToken{kind: wordToken, start: i}
+	var word strings.Builder
+	for i < len(text) {
+		c := text[i]
+		if c == ' ' || c == '\t' || c == '\n' || c == ';' || (c == '|' || c == '&') && !isRedirection(text, i) {
+			break
+		}
+		switch c {
+		case '\\':
+			if i+1 == len(text) {
+				word.WriteByte(c)
+				i++
+				continue
+			}
+			word.WriteByte(text[i+1])
+			i += 2
+		case '\'':
+			end := strings.IndexByte(text[i+1:], '\'')
+			if end < 0 {
+				word.WriteString(text[i+1:])
+				token.parts = append(token.parts, wordPart{kind: SyntaxString, start: i, end: len(text)})
+				i = len(text)
+				continue
+			}
+			word.WriteString(text[i+1 : i+1+end])
+			token.parts = append(token.parts, wordPart{kind: SyntaxString, start: i, end: i + end + 2})
+			i += end + 2
+		case '"':
+			i = scanDoubleQuoted(text, i, &word, &token.parts)
+		default:
+			end, ok := variableEnd(text, i)
+			if !ok {
+				word.WriteByte(c)
+				i++
+				continue
+			}
+			word.WriteString(text[i:end])
+			token.parts = append(token.parts, wordPart{kind: SyntaxVariable, start: i, end: end})
+			i = end
+		}
+	}
+	token.end = i
+	token.text = word.String()
+	return token
+}
+
+// scanDoubleQuoted appends the unquoted text of the double quoted string at text[i] to word
+// and its parts to parts, and returns the offset just past its closing quote
+func scanDoubleQuoted(text string, i int, word *strings.Builder, parts *[]wordPart) int {
+	closing := i + 1
+	for closing < len(text) && text[closing] != '"' {
+		if text[closing] == '\\' {
+			closing++
+		}
+		closing++
+	}
+	closing = min(closing, len(text))
+	end := min(closing+1, len(text))
+	*parts = append(*parts, wordPart{kind: SyntaxString, start: i, end: end})
+
+	inner := text[:closing]
+	for j := i + 1; j < closing; j++ {
+		if inner[j] == '\\' && j+1 < closing && strings.IndexByte("\"\\$`", inner[j+1]) >= 0 {
+			j++
+		} else if varEnd, ok := variableEnd(inner, j); ok {
+			// Expansions still happen inside double quotes
+			word.WriteString(inner[j:varEnd])
+			*parts = append(*parts, wordPart{kind: SyntaxVariable, start: j, end: varEnd})
+			j = varEnd - 1
+			continue
+		}
+		word.WriteByte(inner[j])
+	}
+	return end
+}
+
+// variableEnd reports whether text[i] starts a parameter expansion or command
+// substitution like $HOME, ${1:-x}, $(date) or `date` and returns the offset just past it
+func variableEnd(text string, i int) (int, bool) {
+	if text[i] == '`' {
+		end := strings.IndexByte(text[i+1:], '`')
+		if end < 0 {
+			return len(text), true
+		}
+		return i + end + 2, true
+	}
+	if text[i] != '$' || i+1 == len(text) {
+		return 0, false
+	}
+
+	switch c := text[i+1]; {
+	case c == '{' || c == '(':
+		closing := byte('}')
+		if c == '(' {
+			closing = ')'
+		}
+		depth := 0
+		for j := i + 1; j < len(text); j++ {
+			switch text[j] {
+			case c:
+				depth++
+			case closing:
+				depth--
+				if depth == 0 {
+					return j + 1, true
+				}
+			}
+		}
+		return len(text), true
+	case c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z':
+		j := i + 2
+		for j < len(text) && (text[j] == '_' || 'a' <= text[j] && text[j] <= 'z' || 'A' <= text[j] && text[j] <= 'Z' || '0' <= text[j] && text[j] <= '9') {
+			j++
+		}
+		return j, true
+	case '0' <= c && c <= '9' || strings.IndexByte("?$!#@*-", c) >= 0:
+		return i + 2, true
+	}
+	return 0, false
+}
+
+// isRedirection reports whether the & or | at text[i] belongs to a redirection
+// like 2>&1, &>log or >|log rather than being a control operator
+func isRedirection(text string, i int) bool {
+	if text[i] == '|' {
+		return i > 0 && text[i-1] == '>'
+	}
+	return i > 0 && (text[i-1] == '>' || text[i-1] == '<') || i+1 < len(text) && text[i+1] == '>'
+}
+
+// newSimpleCommand builds a SimpleCommand from its words, reporting false if it has no program
+func newSimpleCommand(words []Word, piped bool) (SimpleCommand, bool) {
+	for len(words) > 0 && isAssignment(words[0].Text) {
+		words = words[1:]
+	}
+	if len(words) == 0 {
+		return SimpleCommand{}, false
+	}
+
+	cmd := SimpleCommand{Program: words[0], Args: words[1:], Piped: piped}
+	for _, arg := range cmd.Args {
+		if !strings.HasPrefix(arg.Text, "-") {
+			cmd.Subcommand = arg
+			break
+		}
+	}
+	return cmd, true
+}
+
+// isAssignment reports whether word sets a variable for the command, like FOO=bar
+func isAssignment(word string) bool {
+	name, _, ok := strings.Cut(word, "=")
+	if !ok || name == "" {
+		return false
+	}
+	for i, c := range name {
+		if !(c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || i > 0 && '0' <= c && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// ShellQuote quotes s as a single shell word, leaving words that need no quoting as they are
+func ShellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	for _, c := range s {
+		if !(c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.ContainsRune("@%+=:,./-", c)) {
+			return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+		}
+	}
+	return s
+}
+
+// programName returns the name a program is run by, without its directory
+func programName(program string) string {
+	return path.Base(program)
+}
@@ -0,0 +1,97 @@
+package history
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCommandLine(t *testing.T) {
+	// words lists the program and arguments of each simple command, with a leading "|" if it's piped
+	tests := []struct {
+		text  string
+		words [][]string
+		subs  []string
+	}{
+		{"git push origin main", [][]string{{"git", "push", "origin", "main"}}, []string{"push"}},
+		{"docker -H host compose up", [][]string{{"docker", "-H", "host", "compose", "up"}}, []string{"host"}},
+		{"ls -la", [][]string{{"ls", "-la"}}, []string{""}},
+		{"cat log | grep -i error | wc -l", [][]string{{"cat", "log"}, {"|", "grep", "-i", "error"}, {"|", "wc", "-l"}}, []string{"log", "error", ""}},
+		{"make && make install; echo done", [][]string{{"make"}, {"make", "install"}, {"echo", "done"}}, []string{"", "install", "done"}},
+		{"false || true & sleep 1", [][]string{{"false"}, {"true"}, {"sleep", "1"}}, []string{"", "", "1"}},
+		{"make 2>&1 |& tee log", [][]string{{"make"}, {"|", "tee", "log"}}, []string{"", "log"}},
+		{"make 2>&1 | tee log", [][]string{{"make"}, {"|", "tee", "log"}}, []string{"", "log"}},
+		{"make > log", [][]string{{"make"}}, []string{""}},
+		{"echo x >| out", [][]string{{"echo", "x"}}, []string{"x"}},
+		{"<in sort -r >out 2>>err uniq", [][]string{{"sort", "-r", "uniq"}}, []string{"uniq"}},
+		{`echo ">" x`, [][]string{{"echo", ">", "x"}}, []string{">"}},
+		{`echo 'a | b' "c \"d\" $e" f\ g`, [][]string{{"echo", "a | b", `c "d" $e`, "f g"}}, []string{"a | b"}},
+		{"FOO=1 BAR=x go test ./...", [][]string{{"go", "test", "./..."}}, []string{"test"}},
+		{"echo hi # | not a pipe", [][]string{{"echo", "hi"}}, []string{"hi"}},
+		{"echo $(date +%s; id) \"${x:-a b}\" y", [][]string{{"echo", "$(date +%s; id)", "${x:-a b}", "y"}}, []string{"$(date +%s; id)"}},
+		{"echo it's", [][]string{{"echo", "its"}}, []string{"its"}},
+		{"  ; | ", nil, nil},
+	}
+
+	for _, tt := range tests {
+		var words [][]string
+		var subs []string
+		for _, cmd := range ParseCommandLine(tt.text) {
+			var w []string
+			if cmd.Piped {
+				w = append(w, "|")
+			}
+			w = append(w, cmd.Program.Text)
+			for _, arg := range cmd.Args {
+				w = append(w, arg.Text)
+			}
+			words = append(words, w)
+			subs = append(subs, cmd.Subcommand.Text)
+		}
+		if !reflect.DeepEqual(words, tt.words) {
+			t.Errorf("ParseCommandLine(%q) words = %q, want %q", tt.text, words, tt.words)
+		}
+		if !reflect.DeepEqual(subs, tt.subs) {
+			t.Errorf("ParseCommandLine(%q) subcommands = %q, want %q", tt.text, subs, tt.subs)
+		}
+	}
+}
+
+func TestParseCommandLineOffsets(t *testing.T) {
+	text := `sudo "my app" --flag`
+	cmds := ParseCommandLine(text)
+	if len(cmds) != 1 || len(cmds[0].Args) != 2 {
+		t.Fatalf("ParseCommandLine(%q) = %+v, want one command with two arguments", text, cmds)
+	}
+	if got := text[cmds[0].Args[0].Start:cmds[0].Args[0].End]; got != `"my app"` {
+		t.Errorf("first argument spans %q, want %q", got, `"my app"`)
+	}
+	if got := text[cmds[0].Args[1].Start:cmds[0].Args[1].End]; got != "--flag" {
+		t.Errorf("second argument spans %q, want %q", got, "--flag")
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		word string
+		want string
+	}{
+		{"ls", "ls"},
+		{"/usr/bin/git", "/usr/bin/git"},
+		{"--color=auto", "--color=auto"},
+		{"", "''"},
+		{"hello world", "'hello world'"},
+		{"it's", `'it'\''s'`},
+		{"$HOME", "'$HOME'"},
+		{"a;rm -rf /", "'a;rm -rf /'"},
+	}
+
+	for _, tt := range tests {
+		if got := ShellQuote(tt.word); got != tt.want {
+			t.Errorf("ShellQuote(%q) = %s, want %s", tt.word, got, tt.want)
+		}
+		// Quoting must round trip through the parser
+		if cmds := ParseCommandLine("echo " + ShellQuote(tt.word)); len(cmds) != 1 || len(cmds[0].Args) != 1 || cmds[0].Args[0].Text != tt.word {
+			t.Errorf("ParseCommandLine(%q) doesn't give back %q", "echo "+ShellQuote(tt.word), tt.word)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package history
+
+import "time"
+
+// DedupeMode determines how repeated commands are collapsed
+type DedupeMode string
+
+const (
+	// DedupeNone shows every occurrence of a command
+	DedupeNone DedupeMode = "none"
+	// DedupeKeepMostRecent keeps only the latest occurrence of each command
+	DedupeKeepMostRecent DedupeMode = "most-recent"
+	// DedupeKeepFirst keeps only the earliest occurrence of each command
+	DedupeKeepFirst DedupeMode = "first"
+)
+
+// dedupeModes lists the dedupe modes in the order they are cycled through
+var dedupeModes = []DedupeMode{DedupeNone, DedupeKeepMostRecent, DedupeKeepFirst}
+
+// ParseDedupeMode converts a config value into a DedupeMode, reporting whether it is known
+func ParseDedupeMode(value string) (DedupeMode, bool) {
+	for _, mode := range dedupeModes {
+		if string(mode) == value {
+			return mode, true
+		}
+	}
+	return DedupeNone, false
+}
+
+// Next returns the dedupe mode that follows d
+func (d DedupeMode) Next() DedupeMode {
+	for i, mode := range dedupeModes {
+		if mode == d {
+			return dedupeModes[(i+1)%len(dedupeModes)]
+		}
+	}
+	return DedupeNone
+}
+
+// Usage summarises every occurrence of one command text
+type Usage struct {
+	Count     int
+	FirstUsed time.Time // Earliest known timestamp, zero if none was recorded
+	LastUsed  time.Time // Latest known timestamp, zero if none was recorded
+}
+
+// UsageStats maps each unique command text to its usage
+type UsageStats map[string]*Usage
+
+// NewUsageStats collects usage statistics for the given commands
+func NewUsageStats(commands []Command) UsageStats {
+	stats := make(UsageStats, len(commands))
+	stats.Add(commands...)
+	return stats
+}
+
+// Add records further occurrences, e.g. commands appended while sheek is open.
+// Changed entries are replaced rather than updated in place, so a copy made with
+// maps.Clone can be added to while the stats it was copied from are still read.
+func (s UsageStats) Add(commands ...Command) {
+	for _, cmd := range commands {
+		usage := &Usage{}
+		if old, ok := s[cmd.Text]; ok {
+			*usage = *old
+		}
+		s[cmd.Text] = usage
+		usage.Count++
+
+		if !cmd.Timestamp.IsZero() {
+			if usage.FirstUsed.IsZero() || cmd.Timestamp.Before(usage.FirstUsed) {
+				usage.FirstUsed = cmd.Timestamp
+			}
+			if cmd.Timestamp.After(usage.LastUsed) {
+				usage.LastUsed = cmd.Timestamp
+			}
+		}
+	}
+}
+
+// Dedupe removes repeated commands according to mode, keeping the order of commands.
+// Which occurrence survives is decided by history position among commands, so the
+// result is the same whatever order they were searched or sorted in, and filtering
+// out some occurrences first, like by time range, keeps the latest or earliest of
+// those that are left.
+func Dedupe(commands []Command, mode DedupeMode) []Command {
+	if mode == DedupeNone || len(commands) == 0 {
+		return commands
+	}
+
+	keep := make(map[string]int, len(commands))
+	for _, cmd := range commands {
+		index, ok := keep[cmd.Text]
+		if !ok || mode == DedupeKeepMostRecent && cmd.Index > index || mode == DedupeKeepFirst && cmd.Index < index {
+			keep[cmd.Text] = cmd.Index
+		}
+	}
+
+	deduped := make([]Command, 0, len(keep))
+	for _, cmd := range commands {
+		if keep[cmd.Text] == cmd.Index {
+			deduped = append(deduped, cmd)
+		}
+	}
+	return deduped
+}
@@ -0,0 +1,108 @@
+package history
+
+import (
+	"maps"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDedupe(t *testing.T) {
+	cmds := []Command{
+		{Index: 1, Text: "git status", Timestamp: time.Unix(100, 0)},
+		{Index: 2, Text: "make test", Timestamp: time.Unix(200, 0)},
+		{Index: 3, Text: "git status", Timestamp: time.Unix(300, 0)},
+		{Index: 4, Text: "ls"},
+		{Index: 5, Text: "git status", Timestamp: time.Unix(500, 0)},
+	}
+	stats := NewUsageStats(cmds)
+
+	tests := []struct {
+		mode        DedupeMode
+		wantIndexes []int
+	}{
+		{DedupeNone, []int{1, 2, 3, 4, 5}},
+		{DedupeKeepMostRecent, []int{2, 4, 5}},
+		{DedupeKeepFirst, []int{1, 2, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			got := Dedupe(cmds, tt.mode)
+			if len(got) != len(tt.wantIndexes) {
+				t.Fatalf("Dedupe(%s) = %v, want indexes %v", tt.mode, got, tt.wantIndexes)
+			}
+			for i, cmd := range got {
+				if cmd.Index != tt.wantIndexes[i] {
+					t.Errorf("Dedupe(%s)[%d].Index = %d, want %d", tt.mode, i, cmd.Index, tt.wantIndexes[i])
+				}
+			}
+		})
+	}
+
+	usage := stats["git status"]
+	if usage.Count != 3 || !usage.FirstUsed.Equal(time.Unix(100, 0)) || !usage.LastUsed.Equal(time.Unix(500, 0)) {
+		t.Errorf("usage of %q = %+v, want count 3 used from 100 to 500", "git status", usage)
+	}
+	if usage := stats["ls"]; usage.Count != 1 || !usage.FirstUsed.IsZero() {
+		t.Errorf("usage of %q = %+v, want count 1 without timestamps", "ls", usage)
+	}
+}
+
+func TestDedupeTimeRange(t *testing.T) {
+	cmds := []Command{
+		{Index: 1, Text: "git status", Timestamp: time.Unix(100, 0)},
+		{Index: 2, Text: "git status", Timestamp: time.Unix(200, 0)},
+		{Index: 3, Text: "make test", Timestamp: time.Unix(250, 0)},
+		{Index: 4, Text: "git status", Timestamp: time.Unix(300, 0)},
+		{Index: 5, Text: "git status", Timestamp: time.Unix(400, 0)},
+	}
+	// Leaves out the first and the latest "git status"
+	timeRange := TimeRange{After: time.Unix(150, 0), Before: time.Unix(350, 0)}
+
+	tests := []struct {
+		mode        DedupeMode
+		wantIndexes []int
+	}{
+		{DedupeNone, []int{2, 3, 4}},
+		{DedupeKeepMostRecent, []int{3, 4}},
+		{DedupeKeepFirst, []int{2, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			var got []int
+			for _, cmd := range Dedupe(timeRange.Filter(cmds), tt.mode) {
+				got = append(got, cmd.Index)
+			}
+			if !slices.Equal(got, tt.wantIndexes) {
+				t.Errorf("Dedupe(%s) of commands in range = indexes %v, want %v", tt.mode, got, tt.wantIndexes)
+			}
+		})
+	}
+}
+
+func TestUsageStatsAdd(t *testing.T) {
+	stats := NewUsageStats([]Command{{Index: 1, Text: "make"}})
+	stats.Add(Command{Index: 2, Text: "make", Timestamp: time.Unix(100, 0)})
+
+	if !stats["make"].LastUsed.Equal(time.Unix(100, 0)) {
+		t.Errorf("LastUsed = %v, want %v", stats["make"].LastUsed, time.Unix(100, 0))
+	}
+	if stats["make"].Count != 2 {
+		t.Errorf("Count = %d, want 2", stats["make"].Count)
+	}
+}
+
+func TestUsageStatsAddToClone(t *testing.T) {
+	stats := NewUsageStats([]Command{{Index: 1, Text: "make"}})
+	clone := maps.Clone(stats)
+	clone.Add(Command{Index: 2, Text: "make"}, Command{Index: 3, Text: "ls"})
+
+	if stats["make"].Count != 1 || stats["ls"] != nil {
+		t.Errorf("original stats changed to make %+v and ls %+v", stats["make"], stats["ls"])
+	}
+	if clone["make"].Count != 2 || clone["ls"].Count != 1 {
+		t.Errorf("cloned stats = make %+v and ls %+v, want counts 2 and 1", clone["make"], clone["ls"])
+	}
+}
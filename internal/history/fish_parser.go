@@ -0,0 +1,68 @@
+package history
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	fishCmdPrefix  = "- cmd:"
+	fishWhenPrefix = "  when:"
+)
+
+// fishParser parses fish's YAML-like fish_history records
+type fishParser struct {
+	commandBuilder
+}
+
+// ParseFishHistory converts raw fish_history lines into commands.
+// Each entry starts with a "- cmd:" line followed by an indented "when:" line;
+// other fields such as "paths:" are ignored.
+func ParseFishHistory(rawLines []string) []Command {
+	return ParseHistory(ShellFish, rawLines)
+}
+
+func (p *fishParser) parseLine(line string) bool {
+	switch {
+	case strings.HasPrefix(line, fishCmdPrefix):
+		p.finish()
+		p.timestamp = time.Time{}
+		cmd := strings.TrimPrefix(line[len(fishCmdPrefix):], " ")
+		p.current.WriteString(unescapeFishCommand(cmd))
+		return true
+	case strings.HasPrefix(line, fishWhenPrefix):
+		epoch, err := strconv.ParseInt(strings.TrimSpace(line[len(fishWhenPrefix):]), 10, 64)
+		if err == nil {
+			p.timestamp = time.Unix(epoch, 0)
+		}
+	}
+	return false
+}
+
+// unescapeFishCommand decodes the escaping fish applies to the cmd field:
+// "\n" is an embedded newline and "\\" a literal backslash.
+func unescapeFishCommand(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			switch s[i+1] {
+			case 'n':
+				b.WriteByte('\n')
+				i++
+				continue
+			case '\\':
+				b.WriteByte('\\')
+				i++
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
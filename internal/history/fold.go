@@ -0,0 +1,59 @@
+package history
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// foldRune maps r to a canonical case so that runes which are equal under
+// Unicode simple case folding (e.g. "K", "k" and the Kelvin sign) compare equal.
+// Folding is rune-for-rune, so rune positions in folded text match the original.
+func foldRune(r rune) rune {
+	if r < utf8.RuneSelf {
+		if 'A' <= r && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}
+
+	folded := unicode.ToLower(r)
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if lower := unicode.ToLower(f); lower < folded {
+			folded = lower
+		}
+	}
+	return folded
+}
+
+// foldString applies foldRune to every rune of s
+func foldString(s string) string {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c >= utf8.RuneSelf || ('A' <= c && c <= 'Z') {
+			return strings.Map(foldRune, s)
+		}
+	}
+	return s
+}
+
+// occurrencePositions returns the rune positions covered by every non-overlapping
+// occurrence of query in text, both already case folded
+func occurrencePositions(text, query string) []int {
+	queryRunes := utf8.RuneCountInString(query)
+
+	var positions []int
+	byteOffset, runeOffset := 0, 0
+	for {
+		idx := strings.Index(text[byteOffset:], query)
+		if idx == -1 {
+			break
+		}
+		runeOffset += utf8.RuneCountInString(text[byteOffset : byteOffset+idx])
+		for i := 0; i < queryRunes; i++ {
+			positions = append(positions, runeOffset+i)
+		}
+		runeOffset += queryRunes
+		byteOffset += idx + len(query)
+	}
+	return positions
+}
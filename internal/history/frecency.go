@@ -0,0 +1,34 @@
+package history
+
+import (
+	"math"
+	"time"
+)
+
+// FrecencyWeights tunes how occurrence count and recency combine into a frecency score
+type FrecencyWeights struct {
+	Frequency float64       // Points per doubling of the occurrence count
+	Recency   float64       // Points for a command used just now, halving every HalfLife
+	HalfLife  time.Duration // Age at which the recency contribution halves
+}
+
+// FrecencyScore rates a command by how often and how recently it was run.
+// The count is log-scaled so a handful of recent uses can outrank a long tail
+// of old ones; commands without any timestamp only score on frequency.
+func FrecencyScore(cmd Command, usage *Usage, now time.Time, weights FrecencyWeights) float64 {
+	count := 1
+	lastUsed := cmd.Timestamp
+	if usage != nil {
+		count = usage.Count
+		if usage.LastUsed.After(lastUsed) {
+			lastUsed = usage.LastUsed
+		}
+	}
+
+	score := weights.Frequency * math.Log2(1+float64(count))
+	if !lastUsed.IsZero() && weights.HalfLife > 0 {
+		age := max(0, now.Sub(lastUsed))
+		score += weights.Recency * math.Exp2(-float64(age)/float64(weights.HalfLife))
+	}
+	return score
+}
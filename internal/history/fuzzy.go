@@ -0,0 +1,294 @@
+package history
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Scores for fuzzy alignment, modelled on fzf's v2 algorithm.
+// Every matched rune is worth scoreMatch plus the bonus of its position; the first
+// skipped rune between two matches costs scoreGapStart and every further one scoreGapExtension.
+const (
+	scoreMatch        = 16
+	scoreGapStart     = -3
+	scoreGapExtension = -1
+
+	// bonusBoundary rewards a match at the start of a word
+	bonusBoundary = scoreMatch / 2
+	// bonusBoundaryWhite rewards the first rune after whitespace, i.e. the start of a shell word
+	bonusBoundaryWhite = bonusBoundary + 2
+	// bonusBoundaryDelimiter rewards the first rune after a path separator or similar delimiter
+	bonusBoundaryDelimiter = bonusBoundary + 1
+	// bonusNonWord rewards matching punctuation itself, such as the dashes of "--force"
+	bonusNonWord = scoreMatch / 2
+	// bonusCamel123 rewards a camelCase hump or the first digit of a number
+	bonusCamel123 = bonusBoundary + scoreGapExtension
+	// bonusConsecutive is the least bonus a rune continuing a run of matches gets
+	bonusConsecutive = -(scoreGapStart + scoreGapExtension)
+	// bonusFirstCharMultiplier weights the position of the first query rune more heavily
+	bonusFirstCharMultiplier = 2
+
+	// maxAlignCells bounds the alignment matrix; bigger inputs fall back to a greedy match
+	maxAlignCells = 1 << 17
+)
+
+// delimiterChars separate path segments, key/value pairs and pipelines
+const delimiterChars = "/,:;|"
+
+// charClass classifies runes to find word boundaries
+type charClass int
+
+const (
+	charWhite charClass = iota
+	charNonWord
+	charDelimiter
+	charLower
+	charUpper
+	charLetter
+	charNumber
+)
+
+func classOf(r rune) charClass {
+	switch {
+	case 'a' <= r && r <= 'z':
+		return charLower
+	case 'A' <= r && r <= 'Z':
+		return charUpper
+	case '0' <= r && r <= '9':
+		return charNumber
+	case unicode.IsSpace(r):
+		return charWhite
+	case strings.ContainsRune(delimiterChars, r):
+		return charDelimiter
+	case unicode.IsLower(r):
+		return charLower
+	case unicode.IsUpper(r):
+		return charUpper
+	case unicode.IsLetter(r):
+		return charLetter
+	case unicode.IsNumber(r):
+		return charNumber
+	}
+	return charNonWord
+}
+
+// positionBonus returns the bonus for matching a rune of class cur that follows a rune of class prev.
+// Dashes are non-word runes, so the first letter of "-v" or "--verbose" counts as a word start.
+func positionBonus(prev, cur charClass) int {
+	if cur > charDelimiter {
+		switch prev {
+		case charWhite:
+			return bonusBoundaryWhite
+		case charDelimiter:
+			return bonusBoundaryDelimiter
+		case charNonWord:
+			return bonusBoundary
+		}
+	}
+	if prev == charLower && cur == charUpper || prev != charNumber && cur == charNumber {
+		return bonusCamel123
+	}
+	switch cur {
+	case charNonWord, charDelimiter:
+		return bonusNonWord
+	case charWhite:
+		return bonusBoundaryWhite
+	}
+	return 0
+}
+
+// aligner holds the scratch buffers of fuzzy alignment so they can be reused across commands,
+// along with the last command line parsed for selector terms.
+// An aligner must not be used by more than one goroutine at a time.
+type aligner struct {
+	folded []rune
+	bonus  []int
+	first  []int
+	score  []int
+	run    []int
+
+	lineText string
+	line     []SimpleCommand
+}
+
+// commandLine returns ParseCommandLine(text), reusing the result for the same text
+// so several selector terms parse a command only once
+func (a *aligner) commandLine(text string) []SimpleCommand {
+	if a.line == nil || a.lineText != text {
+		a.lineText, a.line = text, ParseCommandLine(text)
+	}
+	return a.line
+}
+
+// fuzzyAlign finds the highest scoring placement of query, already case folded, in text, ignoring case.
+// It returns the score and the rune positions of the matched runes, or nil positions
+// when text doesn't contain every query rune in order.
+func fuzzyAlign(text string, query []rune) (int, []int) {
+	var a aligner
+	return a.align(text, query, false)
+}
+
+// align implements fuzzyAlign. If caseSensitive is set, query isn't folded and
+// text is compared as is.
+//
+// Like fzf's v2 algorithm it fills a score matrix over the part of text that can hold
+// the match and backtracks from the best cell, so "gco" matches the word starts of
+// "git checkout origin" rather than the first "o" after the "c".
+func (a *aligner) align(text string, query []rune, caseSensitive bool) (int, []int) {
+	m := len(query)
+	if m == 0 {
+		return 0, nil
+	}
+
+	bonus := a.bonuses(text, caseSensitive)
+	folded := a.folded
+
+	// first[i] is the earliest position query[i] can be matched at
+	first := resize(&a.first, m)
+	i := 0
+	for j := 0; j < len(folded) && i < m; j++ {
+		if folded[j] == query[i] {
+			first[i] = j
+			i++
+		}
+	}
+	if i < m {
+		return 0, nil
+	}
+
+	// last is the latest position the final query rune can be matched at
+	last := first[m-1]
+	for j := len(folded) - 1; j > last; j-- {
+		if folded[j] == query[m-1] {
+			last = j
+			break
+		}
+	}
+
+	offset := first[0]
+	width := last - offset + 1
+	if width*m > maxAlignCells {
+		return scorePositions(bonus, first), append([]int(nil), first...)
+	}
+
+	// score[i*width+j-offset] is the best score of query[:i+1] placed within text[:j+1];
+	// run holds the length of the run of matches ending there, or 0 if j isn't matched.
+	// Every cell read is written first, so the reused buffers needn't be cleared.
+	score := resize(&a.score, width*m)
+	run := resize(&a.run, width*m)
+	for i := 0; i < m; i++ {
+		row := i * width
+		inGap := false
+		for j := first[i]; j <= last; j++ {
+			cell := row + j - offset
+
+			matchScore, matchRun := -1, 0
+			if folded[j] == query[i] {
+				b := bonus[j]
+				if i == 0 {
+					matchScore, matchRun = scoreMatch+b*bonusFirstCharMultiplier, 1
+				} else {
+					diag := cell - width - 1
+					matchRun = run[diag] + 1
+					if matchRun > 1 {
+						runBonus := bonus[j-matchRun+1]
+						if b >= bonusBoundary && b > runBonus {
+							// A new word starts here, don't let it inherit the run
+							matchRun = 1
+						} else {
+							b = max(b, runBonus, bonusConsecutive)
+						}
+					}
+					matchScore = score[diag] + scoreMatch + b
+				}
+			}
+
+			gapScore := -1
+			if j > first[i] {
+				gapScore = score[cell-1] + scoreGapStart
+				if inGap {
+					gapScore = score[cell-1] + scoreGapExtension
+				}
+			}
+
+			// Ties go to the gap so the earliest of equally good placements wins
+			if matchScore > gapScore {
+				score[cell], run[cell], inGap = matchScore, matchRun, false
+			} else {
+				score[cell], run[cell], inGap = max(gapScore, 0), 0, true
+			}
+		}
+	}
+
+	// Find the best end position, preferring the earliest
+	row := (m - 1) * width
+	end := first[m-1]
+	for j := first[m-1] + 1; j <= last; j++ {
+		if score[row+j-offset] > score[row+end-offset] {
+			end = j
+		}
+	}
+	best := score[row+end-offset]
+
+	// Walk back along the cells the best score was built from
+	positions := make([]int, m)
+	for i, j := m-1, end; i >= 0; j-- {
+		if run[i*width+j-offset] > 0 {
+			positions[i] = j
+			i--
+		}
+	}
+
+	return max(best, 1), positions
+}
+
+// bonuses folds the runes of text into a.folded, or copies them as is if caseSensitive
+// is set, and returns the position bonus of each
+func (a *aligner) bonuses(text string, caseSensitive bool) []int {
+	folded, bonus := a.folded[:0], a.bonus[:0]
+	prev := charWhite
+	for _, r := range text {
+		if caseSensitive {
+			folded = append(folded, r)
+		} else {
+			folded = append(folded, foldRune(r))
+		}
+		cur := classOf(r)
+		bonus = append(bonus, positionBonus(prev, cur))
+		prev = cur
+	}
+	a.folded, a.bonus = folded, bonus
+	return bonus
+}
+
+// resize returns *buf resliced to n elements, growing it if needed
+func resize(buf *[]int, n int) []int {
+	if cap(*buf) < n {
+		*buf = make([]int, n)
+	}
+	return (*buf)[:n]
+}
+
+// scorePositions scores a placement chosen without fuzzyAlign using the same rules
+func scorePositions(bonus []int, positions []int) int {
+	total, runBonus := 0, 0
+	for i, pos := range positions {
+		b := bonus[pos]
+		switch {
+		case i == 0:
+			total += scoreMatch + b*bonusFirstCharMultiplier
+			runBonus = b
+			continue
+		case pos == positions[i-1]+1:
+			if b >= bonusBoundary && b > runBonus {
+				runBonus = b
+			}
+			b = max(b, runBonus, bonusConsecutive)
+		default:
+			total += scoreGapStart + (pos-positions[i-1]-2)*scoreGapExtension
+			runBonus = b
+		}
+		total += scoreMatch + b
+	}
+	return max(total, 1)
+}
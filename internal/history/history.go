@@ -1,18 +1,50 @@
 package history
 
-import "time"
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
 
 type Command struct {
 	Index     int
 	Text      string
 	Timestamp time.Time
+	Duration  time.Duration // Elapsed runtime, only recorded by zsh EXTENDED_HISTORY
 }
 
-func LoadAndParseZshHistory() ([]Command, error) {
-	rawLines, err := LoadZshHistory()
-	if err != nil {
-		return nil, err
+// Shell identifies the shell whose history file should be read
+type Shell string
+
+const (
+	// ShellZsh reads $HISTFILE, $ZDOTDIR/.zsh_history or ~/.zsh_history
+	ShellZsh Shell = "zsh"
+	// ShellBash reads $HISTFILE or ~/.bash_history
+	ShellBash Shell = "bash"
+	// ShellFish reads ~/.local/share/fish/fish_history
+	ShellFish Shell = "fish"
+)
+
+// DetectShell determines which history backend to use.
+// The keybind scripts export SHEEK_SHELL; otherwise the login shell from $SHELL is used.
+// Falls back to zsh when neither names a supported shell.
+func DetectShell() Shell {
+	for _, value := range []string{os.Getenv("SHEEK_SHELL"), os.Getenv("SHELL")} {
+		switch Shell(filepath.Base(value)) {
+		case ShellZsh:
+			return ShellZsh
+		case ShellBash:
+			return ShellBash
+		case ShellFish:
+			return ShellFish
+		}
 	}
-	cmds := ParseZshHistory(rawLines)
-	return cmds, nil
+	return ShellZsh
+}
+
+// LoadAndParseHistoryFiles loads several history files and merges them in timestamp order.
+// Each file's format is guessed from its name, falling back to the given shell.
+func LoadAndParseHistoryFiles(paths []string, shell Shell, opts LoadOptions) ([]Command, error) {
+	cmds, _, err := LoadAndWatchHistoryFiles(paths, shell, opts)
+	return cmds, err
 }
@@ -0,0 +1,526 @@
+package history
+
+import (
+	"context"
+	"math"
+	"math/bits"
+	"regexp"
+	"runtime"
+	"slices"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	// maxTrigramLists caps how many posting lists are intersected for one query;
+	// the rarest few narrow the candidates enough that verifying beats intersecting more
+	maxTrigramLists = 4
+	// parallelMinCandidates is how many candidates each extra search goroutine needs to be worth it
+	parallelMinCandidates = 16384
+)
+
+// Index is an in-memory search index over a list of commands, kept in history order.
+// Command texts are case folded once when indexing. Before any text is compared, exact
+// terms are narrowed to the commands containing all of their trigrams, or all of their
+// bytes if they're shorter, and fuzzy terms to the commands containing every byte of
+// them. The remaining candidates are checked in parallel.
+//
+// An Index is never modified once built, so it can be searched from several goroutines.
+type Index struct {
+	commands []Command
+	folded   []string    // Case folded command texts
+	masks    []uint64    // Bit set of the bytes in each folded text, see charMask
+	bytes    [256]bitmap // Commands whose folded text contains each byte, nil if none does
+	trigrams *gramTable
+	indexed  int // commands[:indexed] are in the tables, later ones are always checked
+}
+
+// bitmap is a set of command positions, one bit each
+type bitmap []uint64
+
+// NewIndex indexes commands, which must be in history order
+func NewIndex(commands []Command) *Index {
+	ix := &Index{
+		commands: commands,
+		folded:   make([]string, len(commands)),
+		masks:    make([]uint64, len(commands)),
+		indexed:  len(commands),
+	}
+	words := (len(commands) + 63) / 64
+	for i, cmd := range commands {
+		folded := foldString(cmd.Text)
+		ix.folded[i] = folded
+		ix.masks[i] = charMask(folded)
+		for j := 0; j < len(folded); j++ {
+			set := ix.bytes[folded[j]]
+			if set == nil {
+				set = make(bitmap, words)
+				ix.bytes[folded[j]] = set
+			}
+			set[i/64] |= 1 << (i % 64)
+		}
+	}
+	ix.trigrams = newGramTable(ix.folded, 3)
+	return ix
+}
+
+// Append returns an index that also holds commands, sharing the tables with ix.
+// Appended commands aren't added to the tables and are checked on every search,
+// which stays cheap for the handful of commands recorded while sheek is open.
+func (ix *Index) Append(commands ...Command) *Index {
+	next := *ix
+	next.commands = slices.Concat(ix.commands, commands)
+	next.folded = slices.Grow(slices.Clip(ix.folded), len(commands))
+	next.masks = slices.Grow(slices.Clip(ix.masks), len(commands))
+	for _, cmd := range commands {
+		folded := foldString(cmd.Text)
+		next.folded = append(next.folded, folded)
+		next.masks = append(next.masks, charMask(folded))
+	}
+	return &next
+}
+
+// Commands returns the indexed commands in history order
+func (ix *Index) Commands() []Command {
+	return ix.commands
+}
+
+// SearchExact returns the commands matching input like SearchExactContext,
+// in history order. It gives up with ctx's error once ctx is done.
+func (ix *Index) SearchExact(ctx context.Context, input string, caseMode CaseMode) ([]Command, error) {
+	query := ParseQuery(input, MatchExact, caseMode)
+	if query.Empty() {
+		return ix.commands, nil
+	}
+
+	matched, err := ix.exactPositions(ctx, query, ix.queryCandidates(query))
+	if err != nil {
+		return nil, err
+	}
+	return ix.commandsAt(matched), nil
+}
+
+// SearchFuzzyWithPositions performs fuzzy matching like SearchFuzzyWithPositionsContext.
+// It gives up with ctx's error once ctx is done.
+func (ix *Index) SearchFuzzyWithPositions(ctx context.Context, input string, caseMode CaseMode) ([]FuzzyMatchResult, error) {
+	query := ParseQuery(input, MatchFuzzy, caseMode)
+	if query.Empty() {
+		return unmatchedResults(ix.commands), nil
+	}
+
+	matches, err := ix.fuzzyMatches(ctx, query, ix.queryCandidates(query))
+	if err != nil {
+		return nil, err
+	}
+	return ix.fuzzyResults(matches), nil
+}
+
+// SearchRegex returns the commands that re matches, in history order.
+// It gives up with ctx's error once ctx is done.
+func (ix *Index) SearchRegex(ctx context.Context, re *regexp.Regexp) ([]Command, error) {
+	matched, err := scanCandidates(ctx, len(ix.commands), nil, func() func(int, []int32) []int32 {
+		return func(i int, out []int32) []int32 {
+			if re.MatchString(ix.commands[i].Text) {
+				out = append(out, int32(i))
+			}
+			return out
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ix.commandsAt(matched), nil
+}
+
+// SearchApproximate matches input with a few typos like SearchApproximateContext.
+// It gives up with ctx's error once ctx is done.
+func (ix *Index) SearchApproximate(ctx context.Context, input string, caseMode CaseMode) ([]FuzzyMatchResult, error) {
+	query := parseApproxQuery(input, caseMode)
+	if len(query) == 0 {
+		return unmatchedResults(ix.commands), nil
+	}
+
+	matches, err := scanCandidates(ctx, len(ix.commands), nil, func() func(int, []approxMatch) []approxMatch {
+		var am approxMatcher
+		return func(i int, out []approxMatch) []approxMatch {
+			if !query.possible(ix.masks[i]) {
+				return out
+			}
+			dist, positions, ok := am.match(query, ix.commands[i].Text)
+			if !ok {
+				return out
+			}
+			return append(out, approxMatch{command: ix.commands[i], distance: dist, positions: positions})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return approxResults(matches), nil
+}
+
+// exactPositions returns the positions of the candidates that match query
+func (ix *Index) exactPositions(ctx context.Context, query *Query, candidates []int32) ([]int32, error) {
+	mask := query.mask()
+	return scanCandidates(ctx, len(ix.commands), candidates, func() func(int, []int32) []int32 {
+		var a aligner
+		return func(i int, out []int32) []int32 {
+			if ix.masks[i]&mask != mask {
+				return out
+			}
+			if _, _, ok := query.match(ix.commands[i].Text, ix.folded[i], &a, false); ok {
+				out = append(out, int32(i))
+			}
+			return out
+		}
+	})
+}
+
+// commandsAt returns the commands at the given positions.
+// Collecting positions first keeps large result sets from being copied while they grow.
+func (ix *Index) commandsAt(positions []int32) []Command {
+	if len(positions) == 0 {
+		return nil
+	}
+	commands := make([]Command, len(positions))
+	for k, i := range positions {
+		commands[k] = ix.commands[i]
+	}
+	return commands
+}
+
+// indexMatch is a fuzzy match of the command at position pos
+type indexMatch struct {
+	pos       int32
+	score     int
+	positions []int
+}
+
+// fuzzyMatches matches query against the candidates and returns the matches in candidate order
+func (ix *Index) fuzzyMatches(ctx context.Context, query *Query, candidates []int32) ([]indexMatch, error) {
+	mask := query.mask()
+	return scanCandidates(ctx, len(ix.commands), candidates, func() func(int, []indexMatch) []indexMatch {
+		var a aligner
+		return func(i int, out []indexMatch) []indexMatch {
+			if ix.masks[i]&mask != mask {
+				return out
+			}
+			score, positions, ok := query.match(ix.commands[i].Text, ix.folded[i], &a, true)
+			if !ok {
+				return out
+			}
+			return append(out, indexMatch{pos: int32(i), score: score, positions: positions})
+		}
+	})
+}
+
+// fuzzyResults sorts matches, which are in history order, by relevance like
+// sortFuzzyMatches and converts them to results. Sorting packed keys rather than the
+// matches themselves keeps it quick for the many matches of a short query.
+func (ix *Index) fuzzyResults(matches []indexMatch) []FuzzyMatchResult {
+	keys := make([]uint64, len(matches))
+	for k, match := range matches {
+		// Higher scores first, then earlier commands
+		score := max(min(match.score, math.MaxInt32), math.MinInt32)
+		keys[k] = uint64(math.MaxInt32-score)<<32 | uint64(k)
+	}
+	slices.Sort(keys)
+
+	results := make([]FuzzyMatchResult, len(keys))
+	for r, key := range keys {
+		match := matches[uint32(key)]
+		results[r] = FuzzyMatchResult{Command: ix.commands[match.pos], Positions: match.positions}
+	}
+	return results
+}
+
+// queryCandidates returns the ascending positions of the commands that may match query,
+// or nil if its terms can't narrow them down. Only groups of a single term that isn't a
+// selector are looked up, since a quoted word like 'a b' needn't appear in the text as it
+// is. Terms too short for a trigram are looked up by their bytes, and so are fuzzy terms,
+// whose runes needn't be adjacent.
+func (ix *Index) queryCandidates(query *Query) []int32 {
+	var candidates []int32
+	var required bitmap // Set of the commands containing every byte looked up
+	for _, group := range query.groups {
+		if len(group) != 1 || group[0].inverse || group[0].kind == termField {
+			continue
+		}
+		text := foldString(group[0].text)
+		if group[0].kind == termFuzzy || len(text) < 3 {
+			required = ix.intersectBytes(required, text)
+			continue
+		}
+
+		list := ix.trigramCandidates(text)
+		if candidates == nil {
+			candidates = list
+		} else {
+			candidates = intersectSorted(candidates, list)
+		}
+	}
+
+	switch {
+	case candidates != nil && required != nil:
+		candidates = slices.DeleteFunc(candidates, func(i int32) bool { return !required.has(int(i)) })
+	case required != nil:
+		candidates = required.positions()
+	case candidates == nil:
+		return nil
+	}
+
+	// Appended commands aren't in the tables
+	candidates = slices.Grow(candidates, len(ix.commands)-ix.indexed)
+	for i := ix.indexed; i < len(ix.commands); i++ {
+		candidates = append(candidates, int32(i))
+	}
+	return candidates
+}
+
+// intersectBytes returns the set of the commands in set that contain every byte of text,
+// where a nil set stands for every indexed command. It reuses set's storage.
+func (ix *Index) intersectBytes(set bitmap, text string) bitmap {
+	if set == nil {
+		set = make(bitmap, (ix.indexed+63)/64)
+		for k := range set {
+			set[k] = ^uint64(0)
+		}
+	}
+	for j := 0; j < len(text); j++ {
+		other := ix.bytes[text[j]]
+		if other == nil {
+			clear(set) // No indexed command contains the byte
+			return set
+		}
+		for k := range set {
+			set[k] &= other[k]
+		}
+	}
+	return set
+}
+
+// has reports whether the set holds position i
+func (b bitmap) has(i int) bool {
+	return b[i/64]&(1<<(i%64)) != 0
+}
+
+// positions returns the ascending positions in the set
+func (b bitmap) positions() []int32 {
+	n := 0
+	for _, word := range b {
+		n += bits.OnesCount64(word)
+	}
+	positions := make([]int32, 0, n)
+	for k, word := range b {
+		for word != 0 {
+			positions = append(positions, int32(k*64+bits.TrailingZeros64(word)))
+			word &= word - 1
+		}
+	}
+	return positions
+}
+
+// trigramCandidates returns the ascending positions of the indexed commands that may
+// contain the folded text, which is at least three bytes long
+func (ix *Index) trigramCandidates(text string) []int32 {
+	var lists [][]int32
+	for j := 0; j+3 <= len(text); j++ {
+		list := ix.trigrams.list(text[j : j+3])
+		if list == nil {
+			return []int32{} // No indexed command contains the text
+		}
+		lists = append(lists, list)
+	}
+	slices.SortFunc(lists, func(a, b []int32) int { return len(a) - len(b) })
+
+	candidates := slices.Clone(lists[0])
+	for _, list := range lists[1:min(len(lists), maxTrigramLists)] {
+		candidates = intersectSorted(candidates, list)
+	}
+	return candidates
+}
+
+// scanCandidates runs a matcher over the candidate positions, or over every position below n
+// when candidates is nil, and returns the matches in candidate order. Large searches are split
+// across goroutines, each with its own matcher from newMatcher.
+func scanCandidates[T any](ctx context.Context, n int, candidates []int32, newMatcher func() func(i int, out []T) []T) ([]T, error) {
+	total := n
+	if candidates != nil {
+		total = len(candidates)
+	}
+
+	workers := max(1, min(runtime.GOMAXPROCS(0), total/parallelMinCandidates))
+	chunkSize := (total + workers - 1) / workers
+	results := make([][]T, workers)
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			match := newMatcher()
+			var out []T
+			for k := w * chunkSize; k < min(total, (w+1)*chunkSize); k++ {
+				if k%searchCheckInterval == 0 && ctx.Err() != nil {
+					return
+				}
+				i := k
+				if candidates != nil {
+					i = int(candidates[k])
+				}
+				out = match(i, out)
+			}
+			results[w] = out
+		}()
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return slices.Concat(results...), nil
+}
+
+// gramTable lists the ascending positions of the commands containing each gram, a run
+// of n bytes of their folded text. The lists are stored back to back in one slice, which
+// takes much less memory and time to build than growing a slice for every gram.
+type gramTable struct {
+	n         int
+	ascii     []int32          // Id+1 of each gram of ASCII bytes by its asciiGramKey, 0 if none
+	other     map[uint32]int32 // Id+1 of every other gram by its gramKey
+	ids       int32            // Number of grams with an id
+	offsets   []int32          // The list of the gram with id k is positions[offsets[k]:offsets[k+1]]
+	positions []int32
+}
+
+// newGramTable lists the grams of n bytes, at most 3, of the folded command texts
+func newGramTable(folded []string, n int) *gramTable {
+	t := &gramTable{n: n, ascii: make([]int32, 1<<(7*n)), other: make(map[uint32]int32)}
+
+	// Count the commands containing each gram, then place them into their lists
+	var counts, last []int32
+	for i, text := range folded {
+		for j := 0; j+n <= len(text); j++ {
+			id := t.id(text[j:j+n], true)
+			if id == len(counts) {
+				counts, last = append(counts, 0), append(last, -1)
+			}
+			if last[id] != int32(i) { // Repeated grams within a command are listed once
+				last[id] = int32(i)
+				counts[id]++
+			}
+		}
+	}
+
+	t.offsets = make([]int32, len(counts)+1)
+	for id, count := range counts {
+		t.offsets[id+1] = t.offsets[id] + count
+	}
+	t.positions = make([]int32, t.offsets[len(counts)])
+	next := counts // Where the next position of each list goes
+	copy(next, t.offsets)
+	for i, text := range folded {
+		for j := 0; j+n <= len(text); j++ {
+			id := t.id(text[j:j+n], false)
+			if last[id] != -int32(i)-2 { // Marked apart from the first pass, whose marks are >= -1
+				last[id] = -int32(i) - 2
+				t.positions[next[id]] = int32(i)
+				next[id]++
+			}
+		}
+	}
+	return t
+}
+
+// list returns the ascending positions of the commands containing gram, or nil if none does
+func (t *gramTable) list(gram string) []int32 {
+	id := t.id(gram, false)
+	if id < 0 || t.offsets[id] == t.offsets[id+1] {
+		return nil
+	}
+	return t.positions[t.offsets[id]:t.offsets[id+1]]
+}
+
+// id returns the id of gram, or -1 if it has none. With add set, a gram without an id
+// is given the next one.
+func (t *gramTable) id(gram string, add bool) int {
+	var id int32
+	ascii := true
+	for i := 0; i < len(gram); i++ {
+		ascii = ascii && gram[i] < utf8.RuneSelf
+	}
+	if ascii {
+		id = t.ascii[asciiGramKey(gram)]
+	} else {
+		id = t.other[gramKey(gram)]
+	}
+	if id == 0 && add {
+		t.ids++
+		id = t.ids
+		if ascii {
+			t.ascii[asciiGramKey(gram)] = id
+		} else {
+			t.other[gramKey(gram)] = id
+		}
+	}
+	return int(id) - 1
+}
+
+// gramKey packs the bytes of a gram
+func gramKey(gram string) uint32 {
+	var key uint32
+	for i := 0; i < len(gram); i++ {
+		key = key<<8 | uint32(gram[i])
+	}
+	return key
+}
+
+// asciiGramKey packs the bytes of a gram of ASCII bytes, 7 bits each
+func asciiGramKey(gram string) uint32 {
+	var key uint32
+	for i := 0; i < len(gram); i++ {
+		key = key<<7 | uint32(gram[i])
+	}
+	return key
+}
+
+// charMask sets one of 64 bits for every byte of s. A text can only contain a query
+// if its mask has every bit of the query's mask set.
+func charMask(s string) uint64 {
+	var mask uint64
+	for i := 0; i < len(s); i++ {
+		mask |= 1 << (s[i] & 63)
+	}
+	return mask
+}
+
+// containsInOrder reports whether every rune of query occurs in s in order
+func containsInOrder(s string, query []rune) bool {
+	i := 0
+	for _, r := range s {
+		if i == len(query) {
+			break
+		}
+		if r == query[i] {
+			i++
+		}
+	}
+	return i == len(query)
+}
+
+// intersectSorted returns the values present in both ascending lists, reusing a's storage
+func intersectSorted(a, b []int32) []int32 {
+	out := a[:0]
+	j := 0
+	for _, v := range a {
+		for j < len(b) && b[j] < v {
+			j++
+		}
+		if j == len(b) {
+			break
+		}
+		if b[j] == v {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,171 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIndexSearchMatchesLinearSearch(t *testing.T) {
+	cmds := []Command{
+		{Index: 1, Text: "git status"},
+		{Index: 2, Text: "git checkout origin"},
+		{Index: 3, Text: "cd /usr/lib/local"},
+		{Index: 4, Text: "cat RÉSUMÉ.md"},
+		{Index: 5, Text: "go test ./..."},
+		{Index: 6, Text: "GIT STASH"},
+	}
+	appended := []Command{
+		{Index: 7, Text: "git stash pop"},
+		{Index: 8, Text: "ls"},
+	}
+	all := append(append([]Command{}, cmds...), appended...)
+	ix := NewIndex(cmds).Append(appended...)
+
+	queries := []string{
+		"", "g", "gi", "git", "GIT S", "sta", "résumé", "local", "missing", "gco", "./", "tash",
+		"git !stash", "^git", "md$", "git | go", "'sta", "!git", "^ls$", "sta | loc ^c", "Git", "STA", "RÉSUMÉ",
+		"cmd:git", "cmd:GIT", "!cmd:git", "sub:stash", "arg:./...", "cmd:go arg:./...",
+		"ls", "op", "é", "Sé", "zq", "gs lo", "git op", "st 'hk", "xyz g",
+	}
+	ctx := context.Background()
+	for _, caseMode := range caseModes {
+		for _, query := range queries {
+			exact, err := ix.SearchExact(ctx, query, caseMode)
+			if err != nil {
+				t.Fatalf("SearchExact(%q, %s) error: %v", query, caseMode, err)
+			}
+			if want, _ := SearchExactContext(ctx, all, query, caseMode); !reflect.DeepEqual(exact, want) {
+				t.Errorf("Index.SearchExact(%q, %s) = %v, want %v", query, caseMode, exact, want)
+			}
+
+			fuzzy, err := ix.SearchFuzzyWithPositions(ctx, query, caseMode)
+			if err != nil {
+				t.Fatalf("SearchFuzzyWithPositions(%q, %s) error: %v", query, caseMode, err)
+			}
+			if want, _ := SearchFuzzyWithPositionsContext(ctx, all, query, caseMode); !reflect.DeepEqual(fuzzy, want) {
+				t.Errorf("Index.SearchFuzzyWithPositions(%q, %s) = %v, want %v", query, caseMode, fuzzy, want)
+			}
+		}
+	}
+}
+
+func TestIndexSearchParallel(t *testing.T) {
+	// Enough commands to split the search across goroutines
+	cmds := benchmarkCommands(4 * parallelMinCandidates)
+	ix := NewIndex(cmds)
+
+	for _, query := range []string{"docker", "k", "ssh dep"} {
+		got, err := ix.SearchExact(context.Background(), query, CaseSmart)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := SearchExact(cmds, query); !reflect.DeepEqual(got, want) {
+			t.Errorf("Index.SearchExact(%q) returned %d commands, want %d", query, len(got), len(want))
+		}
+	}
+}
+
+func TestIntersectSorted(t *testing.T) {
+	got := intersectSorted([]int32{1, 3, 5, 7, 9}, []int32{2, 3, 4, 9, 10})
+	if want := []int32{3, 9}; !reflect.DeepEqual(got, want) {
+		t.Errorf("intersectSorted = %v, want %v", got, want)
+	}
+}
+
+// benchmarkCommands generates a reproducible history of n varied commands
+func benchmarkCommands(n int) []Command {
+	programs := []string{"git", "docker", "kubectl", "ls", "cd", "vim", "go", "npm", "ssh", "grep", "make", "cargo"}
+	words := []string{
+		"status", "commit", "push", "pull", "run", "build", "test", "install", "logs", "exec",
+		"deploy", "origin", "main", "--force", "-rf", "./...", "src", "internal", "config.json",
+		"/var/log/syslog", "~/projects/sheek", "--verbose", "prod", "staging", "api", "worker",
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	cmds := make([]Command, n)
+	for i := range cmds {
+		var b strings.Builder
+		b.WriteString(programs[rng.Intn(len(programs))])
+		for range 1 + rng.Intn(5) {
+			b.WriteByte(' ')
+			b.WriteString(words[rng.Intn(len(words))])
+		}
+		fmt.Fprintf(&b, " %d", rng.Intn(10000))
+		cmds[i] = Command{Index: i + 1, Text: b.String()}
+	}
+	return cmds
+}
+
+const benchmarkHistorySize = 1_000_000
+
+// keystrokeBudget is how long a search of benchmarkHistorySize commands may take to keep
+// up with typing
+const keystrokeBudget = 16 * time.Millisecond
+
+// reportBudget reports the time each of the searches of a benchmark took as a share of
+// keystrokeBudget, so anything above 1 misses it
+func reportBudget(b *testing.B, searches int) {
+	b.ReportMetric(float64(b.Elapsed())/float64(b.N*searches)/float64(keystrokeBudget), "budget/search")
+}
+
+var benchmarkQueries = []string{"k", "42", "deploy prod", "config.json 42", "syslog"}
+
+func BenchmarkSearchExact(b *testing.B) {
+	cmds := benchmarkCommands(benchmarkHistorySize)
+	for _, query := range benchmarkQueries {
+		b.Run(query, func(b *testing.B) {
+			for b.Loop() {
+				SearchExact(cmds, query)
+			}
+			reportBudget(b, 1)
+		})
+	}
+}
+
+func BenchmarkIndexSearchExact(b *testing.B) {
+	ix := NewIndex(benchmarkCommands(benchmarkHistorySize))
+	for _, query := range benchmarkQueries {
+		b.Run(query, func(b *testing.B) {
+			for b.Loop() {
+				ix.SearchExact(context.Background(), query, CaseSmart)
+			}
+			reportBudget(b, 1)
+		})
+	}
+}
+
+func BenchmarkIndexSearchFuzzy(b *testing.B) {
+	ix := NewIndex(benchmarkCommands(benchmarkHistorySize))
+	for _, query := range []string{"kdp", "dplyprd", "syslg"} {
+		b.Run(query, func(b *testing.B) {
+			for b.Loop() {
+				ix.SearchFuzzyWithPositions(context.Background(), query, CaseSmart)
+			}
+			reportBudget(b, 1)
+		})
+	}
+}
+
+func BenchmarkIndexSearchApproximate(b *testing.B) {
+	ix := NewIndex(benchmarkCommands(benchmarkHistorySize))
+	for _, query := range []string{"dokcer", "kubeclt lgos"} {
+		b.Run(query, func(b *testing.B) {
+			for b.Loop() {
+				ix.SearchApproximate(context.Background(), query, CaseSmart)
+			}
+			reportBudget(b, 1)
+		})
+	}
+}
+
+func BenchmarkNewIndex(b *testing.B) {
+	cmds := benchmarkCommands(benchmarkHistorySize)
+	for b.Loop() {
+		NewIndex(cmds)
+	}
+}
@@ -2,33 +2,280 @@ package history
 
 import (
 	"bufio"
+	"bytes"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"unicode/utf8"
 )
 
-func LoadZshHistory() ([]string, error) {
+// LongLinePolicy controls what happens to lines longer than LoadOptions.MaxLineLength
+type LongLinePolicy string
+
+const (
+	// LongLineTruncate keeps the first MaxLineLength bytes of a line and marks it with an ellipsis
+	LongLineTruncate LongLinePolicy = "truncate"
+	// LongLineKeep keeps long lines intact regardless of their size
+	LongLineKeep LongLinePolicy = "keep"
+)
+
+// truncationMarker is appended to lines cut short by LongLineTruncate
+const truncationMarker = "…"
+
+// LoadOptions configures how history files are read
+type LoadOptions struct {
+	MaxLineLength  int            // Lines longer than this many bytes are subject to LongLinePolicy
+	LongLinePolicy LongLinePolicy // What to do with lines over MaxLineLength
+	CacheDir       string         // Directory for the parsed-history cache; empty disables caching
+}
+
+// DefaultLoadOptions returns the options used when none are configured
+func DefaultLoadOptions() LoadOptions {
+	return LoadOptions{
+		MaxLineLength:  64 * 1024,
+		LongLinePolicy: LongLineTruncate,
+	}
+}
+
+// HistoryPath returns the history file the given shell writes to.
+// For zsh and bash an exported $HISTFILE wins; zsh then looks in $ZDOTDIR
+// and fish in $XDG_DATA_HOME before falling back to the home directory.
+func HistoryPath(shell Shell) (string, error) {
+	if shell == ShellZsh || shell == ShellBash {
+		if historyFile := os.Getenv("HISTFILE"); historyFile != "" {
+			return ExpandPath(historyFile)
+		}
+	}
+
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	// Build full path to .zsh_history
-	historyFile := filepath.Join(homeDir, ".zsh_history")
+	switch shell {
+	case ShellBash:
+		return filepath.Join(homeDir, ".bash_history"), nil
+	case ShellFish:
+		dataDir := os.Getenv("XDG_DATA_HOME")
+		if dataDir == "" {
+			dataDir = filepath.Join(homeDir, ".local", "share")
+		}
+		return filepath.Join(dataDir, "fish", "fish_history"), nil
+	default:
+		if zdotdir := os.Getenv("ZDOTDIR"); zdotdir != "" {
+			return filepath.Join(zdotdir, ".zsh_history"), nil
+		}
+		return filepath.Join(homeDir, ".zsh_history"), nil
+	}
+}
 
-	file, err := os.Open(historyFile)
+// HistoryPaths returns the history files to read. Files given on the command line win,
+// then the configured files, separated by ':' like $PATH, and last the shell's own file,
+// see HistoryPath. A configured file beats $HISTFILE, since the keybind scripts pass on
+// the $HISTFILE of the shell sheek was started from, which bash always sets.
+func HistoryPaths(shell Shell, flagged []string, configured string) ([]string, error) {
+	if len(flagged) > 0 {
+		return flagged, nil
+	}
+	if paths := filepath.SplitList(configured); len(paths) > 0 {
+		return paths, nil
+	}
+
+	path, err := HistoryPath(shell)
 	if err != nil {
 		return nil, err
 	}
+	return []string{path}, nil
+}
+
+// ShellForPath guesses a history file's format from its name,
+// returning fallback when the name doesn't mention a known shell.
+func ShellForPath(path string, fallback Shell) Shell {
+	base := strings.ToLower(filepath.Base(path))
+	switch {
+	case strings.Contains(base, "fish"):
+		return ShellFish
+	case strings.Contains(base, "bash"):
+		return ShellBash
+	case strings.Contains(base, "zsh"):
+		return ShellZsh
+	default:
+		return fallback
+	}
+}
+
+// ExpandPath expands a leading "~/" to the user's home directory
+func ExpandPath(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, strings.TrimPrefix(path, "~")), nil
+}
+
+// StreamHistory parses history from r and emits each command as soon as it is complete.
+// Lines of any length are accepted; see LoadOptions for how long lines are treated.
+func StreamHistory(r io.Reader, shell Shell, opts LoadOptions, emit func(Command)) error {
+	_, err := streamHistory(r, shell, opts, resumePoint{}, emit)
+	return err
+}
+
+// resumePoint marks where the last command of a parsed file begins,
+// so a grown file can be parsed again from there instead of from the start.
+type resumePoint struct {
+	Offset  int64 // Byte offset of the line that starts the last command
+	Emitted int   // Number of commands completed before Offset
+}
+
+// streamHistory parses r, which must be positioned at from.Offset, numbering
+// commands after from.Emitted. It returns the resume point for the data read.
+func streamHistory(r io.Reader, shell Shell, opts LoadOptions, from resumePoint, emit func(Command)) (resumePoint, error) {
+	emitted := from.Emitted
+	parser := newParser(shell, from.Emitted+1, func(cmd Command) {
+		emitted++
+		emit(cmd)
+	})
+
+	resume := from
+	err := readHistoryLines(r, opts, func(line string, offset int64) {
+		if parser.parseLine(line) {
+			resume = resumePoint{Offset: from.Offset + offset, Emitted: emitted}
+		}
+	})
+	if err != nil {
+		return resume, err
+	}
+	parser.finish()
+	return resume, nil
+}
+
+// fileState records how far a history file has been parsed
+type fileState struct {
+	Inode  uint64
+	Size   int64 // Bytes parsed
+	Resume resumePoint
+}
+
+// loadHistoryFile parses a whole history file, using the cache when opts enables it
+func loadHistoryFile(path string, shell Shell, opts LoadOptions) ([]Command, fileState, error) {
+	if opts.CacheDir != "" {
+		return loadHistoryFileCached(path, shell, opts)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fileState{}, err
+	}
 	defer file.Close()
 
-	var lines []string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fileState{}, err
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, err
+	var cmds []Command
+	state, err := parseFileFrom(file, info, shell, opts, resumePoint{}, func(cmd Command) {
+		cmds = append(cmds, cmd)
+	})
+	if err != nil {
+		return nil, fileState{}, err
+	}
+	return cmds, state, nil
+}
+
+// parseFileFrom parses file starting at the given resume point and reports the new state
+func parseFileFrom(file *os.File, info os.FileInfo, shell Shell, opts LoadOptions, from resumePoint, emit func(Command)) (fileState, error) {
+	if _, err := file.Seek(from.Offset, io.SeekStart); err != nil {
+		return fileState{}, err
+	}
+	resume, err := streamHistory(file, shell, opts, from, emit)
+	if err != nil {
+		return fileState{}, err
+	}
+	size, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fileState{}, err
+	}
+	return fileState{Inode: fileInode(info), Size: size, Resume: resume}, nil
+}
+
+// readHistoryLines calls fn for every line in r, along with the byte offset the
+// line starts at, without a fixed line-length limit.
+// NUL bytes, which only appear in files corrupted by a crash, are dropped.
+func readHistoryLines(r io.Reader, opts LoadOptions, fn func(line string, offset int64)) error {
+	reader := bufio.NewReaderSize(r, 64*1024)
+	limit := opts.MaxLineLength
+	if opts.LongLinePolicy == LongLineKeep {
+		limit = 0
+	}
+
+	var (
+		line      []byte
+		truncated bool
+		lastByte  byte  // last byte of the line, even if it was truncated away
+		offset    int64 // offset of the current line
+		read      int64 // bytes consumed so far
+	)
+
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if err != nil && err != bufio.ErrBufferFull && err != io.EOF {
+			return err
+		}
+		read += int64(len(chunk))
+
+		content := bytes.TrimSuffix(chunk, []byte("\n"))
+		if len(content) > 0 {
+			lastByte = content[len(content)-1]
+		}
+		if !truncated {
+			if limit > 0 && len(line)+len(content) > limit {
+				content = content[:limit-len(line)]
+				truncated = true
+			}
+			line = append(line, content...)
+		}
+
+		if err == bufio.ErrBufferFull {
+			// Line continues beyond the buffer; keep reading
+			continue
+		}
+		if err == nil || len(line) > 0 {
+			fn(finishLine(line, truncated, lastByte), offset)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		line, truncated, lastByte, offset = line[:0], false, 0, read
+	}
+}
+
+// finishLine converts the raw bytes of a line into a string ready for parsing
+func finishLine(line []byte, truncated bool, lastByte byte) string {
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	if bytes.IndexByte(line, 0) != -1 {
+		line = bytes.ReplaceAll(line, []byte{0}, nil)
+	}
+	if !truncated {
+		return string(line)
+	}
+
+	// Don't leave half a UTF-8 sequence at the cut
+	for i := 0; i < utf8.UTFMax-1 && len(line) > 0; i++ {
+		if r, size := utf8.DecodeLastRune(line); r != utf8.RuneError || size != 1 {
+			break
+		}
+		line = line[:len(line)-1]
+	}
+
+	text := string(line) + truncationMarker
+	if lastByte == '\\' {
+		// Keep the trailing backslash so multi-line zsh commands stay joined
+		text += "\\"
 	}
-	return lines, nil
+	return text
 }
@@ -1,16 +1,163 @@
 package history
 
 import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 )
 
-func TestLoadAndParseZshHistory(t *testing.T) {
-	lines, err := LoadAndParseZshHistory()
+func TestHistoryPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tests := []struct {
+		name     string
+		shell    Shell
+		histfile string
+		zdotdir  string
+		want     string
+	}{
+		{name: "zsh default", shell: ShellZsh, want: filepath.Join(home, ".zsh_history")},
+		{name: "zsh ZDOTDIR", shell: ShellZsh, zdotdir: "/etc/zdot", want: "/etc/zdot/.zsh_history"},
+		{name: "zsh HISTFILE wins", shell: ShellZsh, histfile: "~/.local/state/zsh/history", zdotdir: "/etc/zdot", want: filepath.Join(home, ".local/state/zsh/history")},
+		{name: "bash default", shell: ShellBash, want: filepath.Join(home, ".bash_history")},
+		{name: "bash HISTFILE", shell: ShellBash, histfile: "/var/hist", want: "/var/hist"},
+		{name: "fish ignores HISTFILE", shell: ShellFish, histfile: "/var/hist", want: filepath.Join(home, ".local/share/fish/fish_history")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HISTFILE", tt.histfile)
+			t.Setenv("ZDOTDIR", tt.zdotdir)
+			t.Setenv("XDG_DATA_HOME", "")
+
+			got, err := HistoryPath(tt.shell)
+			if err != nil {
+				t.Fatalf("HistoryPath(%q) error: %v", tt.shell, err)
+			}
+			if got != tt.want {
+				t.Errorf("HistoryPath(%q) = %q, want %q", tt.shell, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistoryPaths(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tests := []struct {
+		name       string
+		shell      Shell
+		flagged    []string
+		histfile   string
+		configured string
+		want       []string
+	}{
+		{name: "flag beats config and HISTFILE", shell: ShellZsh, flagged: []string{"/f"}, histfile: "/var/hist", configured: "/a", want: []string{"/f"}},
+		{name: "config beats HISTFILE", shell: ShellZsh, histfile: "/var/hist", configured: "/a:/b", want: []string{"/a", "/b"}},
+		{name: "config beats bash HISTFILE", shell: ShellBash, histfile: "~/hist", configured: "/a", want: []string{"/a"}},
+		{name: "HISTFILE without config", shell: ShellBash, histfile: "~/hist", want: []string{filepath.Join(home, "hist")}},
+		{name: "fish ignores HISTFILE", shell: ShellFish, histfile: "/var/hist", want: []string{filepath.Join(home, ".local", "share", "fish", "fish_history")}},
+		{name: "default without either", shell: ShellBash, want: []string{filepath.Join(home, ".bash_history")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HISTFILE", tt.histfile)
+			t.Setenv("ZDOTDIR", "")
+			t.Setenv("XDG_DATA_HOME", "")
+
+			got, err := HistoryPaths(tt.shell, tt.flagged, tt.configured)
+			if err != nil {
+				t.Fatalf("HistoryPaths(%q, %q, %q) error: %v", tt.shell, tt.flagged, tt.configured, err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("HistoryPaths(%q, %q, %q) = %q, want %q", tt.shell, tt.flagged, tt.configured, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadAndParseHistoryFiles(t *testing.T) {
+	dir := t.TempDir()
+	zshFile := filepath.Join(dir, "zsh_history")
+	bashFile := filepath.Join(dir, "bash_history")
+	writeFile(t, zshFile, ": 1700000000:0;first\n: 1700000200:0;third\n")
+	writeFile(t, bashFile, "#1700000100\nsecond\n#1700000300\nfourth\n")
+
+	got, err := LoadAndParseHistoryFiles([]string{zshFile, bashFile}, ShellZsh, DefaultLoadOptions())
 	if err != nil {
-		t.Fatalf("Failed to load history: %v", err)
+		t.Fatalf("LoadAndParseHistoryFiles error: %v", err)
 	}
-	if len(lines) == 0 {
-		t.Errorf("Expected some commands, got zero")
+	assertCommands(t, got,
+		[]string{"first", "second", "third", "fourth"},
+		[]int64{1700000000, 1700000100, 1700000200, 1700000300},
+	)
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
+func TestStreamHistoryLongLines(t *testing.T) {
+	long := strings.Repeat("x", 200*1024) // well past bufio.Scanner's 64 KiB limit
+	input := ": 1700000000:0;echo " + long + "\n: 1700000100:0;ls\n"
+
+	t.Run("keep", func(t *testing.T) {
+		opts := LoadOptions{MaxLineLength: 1024, LongLinePolicy: LongLineKeep}
+		got := streamAll(t, input, ShellZsh, opts)
+		assertCommands(t, got, []string{"echo " + long, "ls"}, []int64{1700000000, 1700000100})
+	})
+
+	t.Run("truncate", func(t *testing.T) {
+		opts := LoadOptions{MaxLineLength: 32, LongLinePolicy: LongLineTruncate}
+		got := streamAll(t, input, ShellZsh, opts)
+		want := ": 1700000000:0;echo " + long
+		assertCommands(t, got, []string{want[15:32] + truncationMarker, "ls"}, []int64{1700000000, 1700000100})
+	})
+
+	t.Run("truncate keeps continuation", func(t *testing.T) {
+		opts := LoadOptions{MaxLineLength: 32, LongLinePolicy: LongLineTruncate}
+		got := streamAll(t, ": 1700000000:0;echo "+long+"\\\n"+"done\n", ShellZsh, opts)
+		want := ": 1700000000:0;echo " + long
+		assertCommands(t, got, []string{want[15:32] + truncationMarker + "\ndone"}, []int64{1700000000})
+	})
+
+	t.Run("truncate on rune boundary", func(t *testing.T) {
+		opts := LoadOptions{MaxLineLength: 5, LongLinePolicy: LongLineTruncate}
+		got := streamAll(t, "ls 日本語\n", ShellBash, opts)
+		assertCommands(t, got, []string{"ls " + truncationMarker}, []int64{0})
+	})
+}
+
+func TestStreamHistoryMalformedLines(t *testing.T) {
+	input := ": 1700000000:0;ls\r\n" +
+		"\x00\x00\x00: 1700000100:0;pwd\n" +
+		": 1700000200:0;echo \xff\xfe\n" +
+		": 99999999999999999999:0;overflowing timestamp\n" +
+		"echo no trailing newline"
+
+	got := streamAll(t, input, ShellZsh, DefaultLoadOptions())
+	assertCommands(t, got,
+		[]string{"ls", "pwd", "echo �", "overflowing timestamp", "echo no trailing newline"},
+		[]int64{1700000000, 1700000100, 1700000200, 0, 0},
+	)
+}
+
+func streamAll(t *testing.T, input string, shell Shell, opts LoadOptions) []Command {
+	t.Helper()
+	var cmds []Command
+	err := StreamHistory(strings.NewReader(input), shell, opts, func(cmd Command) {
+		cmds = append(cmds, cmd)
+	})
+	if err != nil {
+		t.Fatalf("StreamHistory error: %v", err)
 	}
-	t.Logf("Loaded %d commands from history", len(lines))
+	return cmds
 }
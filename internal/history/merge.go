@@ -0,0 +1,50 @@
+package history
+
+import "time"
+
+// MergeCommands merges per-file command lists into one list ordered by timestamp.
+// Each list is assumed to already be in chronological order. Commands without a
+// timestamp stay next to their predecessor from the same file. Indexes are
+// renumbered from 1 so they remain unique across files.
+func MergeCommands(lists ...[]Command) []Command {
+	if len(lists) == 1 {
+		return lists[0]
+	}
+
+	total := 0
+	for _, list := range lists {
+		total += len(list)
+	}
+
+	merged := make([]Command, 0, total)
+	heads := make([]int, len(lists))
+	lastSeen := make([]time.Time, len(lists))
+
+	for len(merged) < total {
+		next := -1
+		var nextTime time.Time
+		for i, list := range lists {
+			if heads[i] >= len(list) {
+				continue
+			}
+			ts := list[heads[i]].Timestamp
+			if ts.IsZero() {
+				ts = lastSeen[i]
+			}
+			if next == -1 || ts.Before(nextTime) {
+				next, nextTime = i, ts
+			}
+		}
+
+		cmd := lists[next][heads[next]]
+		if !cmd.Timestamp.IsZero() {
+			lastSeen[next] = cmd.Timestamp
+		}
+		heads[next]++
+
+		cmd.Index = len(merged) + 1
+		merged = append(merged, cmd)
+	}
+
+	return merged
+}
@@ -0,0 +1,32 @@
+package history
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMergeCommands(t *testing.T) {
+	a := []Command{
+		{Index: 1, Text: "a1", Timestamp: time.Unix(100, 0)},
+		{Index: 2, Text: "a2 (no timestamp)"},
+		{Index: 3, Text: "a3", Timestamp: time.Unix(400, 0)},
+	}
+	b := []Command{
+		{Index: 1, Text: "b1", Timestamp: time.Unix(200, 0)},
+		{Index: 2, Text: "b2", Timestamp: time.Unix(300, 0)},
+	}
+
+	got := MergeCommands(a, b)
+	want := []string{"a1", "a2 (no timestamp)", "b1", "b2", "a3"}
+	if len(got) != len(want) {
+		t.Fatalf("MergeCommands returned %d commands, want %d", len(got), len(want))
+	}
+	for i, cmd := range got {
+		if cmd.Text != want[i] {
+			t.Errorf("MergeCommands[%d].Text = %q, want %q", i, cmd.Text, want[i])
+		}
+		if cmd.Index != i+1 {
+			t.Errorf("MergeCommands[%d].Index = %d, want %d", i, cmd.Index, i+1)
+		}
+	}
+}
@@ -9,70 +9,161 @@ import (
 
 var zshHistoryPrefix = regexp.MustCompile(`^: [0-9]+:[0-9]+;`)
 
-// Save current command if not empty, then reset builder.
-func flushCurrent(commands *[]Command, builder *strings.Builder, index *int, timestamp time.Time) {
-	text := strings.TrimSpace(builder.String())
+// zshMeta is the escape byte zsh uses when writing "metafied" history.
+// Bytes 0x83-0xA2 (and NUL) are stored as zshMeta followed by the byte XOR 0x20.
+const zshMeta = 0x83
+
+// lineParser builds commands incrementally from raw history lines
+type lineParser interface {
+	// parseLine consumes the next raw line of the history file and reports whether
+	// it begins a new command. When it does, every earlier command has been emitted,
+	// so parsing can later resume from this line with a fresh parser.
+	parseLine(line string) bool
+	// finish emits the command still being built, if any
+	finish()
+}
+
+// newParser returns the line parser for the given shell's history format.
+// Emitted commands are numbered from firstIndex.
+func newParser(shell Shell, firstIndex int, emit func(Command)) lineParser {
+	builder := commandBuilder{index: firstIndex, emit: emit}
+	switch shell {
+	case ShellBash:
+		return &bashParser{commandBuilder: builder}
+	case ShellFish:
+		return &fishParser{commandBuilder: builder}
+	default:
+		return &zshParser{commandBuilder: builder}
+	}
+}
+
+// ParseHistory converts raw history lines using the parser for the given shell
+func ParseHistory(shell Shell, rawLines []string) []Command {
+	var commands []Command
+	parser := newParser(shell, 1, func(cmd Command) {
+		commands = append(commands, cmd)
+	})
+	for _, line := range rawLines {
+		parser.parseLine(line)
+	}
+	parser.finish()
+	return commands
+}
+
+// commandBuilder accumulates the command currently being parsed
+type commandBuilder struct {
+	current   strings.Builder
+	index     int
+	timestamp time.Time
+	duration  time.Duration
+	emit      func(Command)
+}
+
+// flush emits the current command if not empty, then resets the builder.
+// Invalid UTF-8 left by a corrupted file is replaced so it can't break rendering.
+func (b *commandBuilder) flush() {
+	text := strings.TrimSpace(b.current.String())
 	if text != "" {
-		*commands = append(*commands, Command{
-			Index:     *index,
-			Text:      text,
-			Timestamp: timestamp,
+		b.emit(Command{
+			Index:     b.index,
+			Text:      strings.ToValidUTF8(text, "�"),
+			Timestamp: b.timestamp,
+			Duration:  b.duration,
 		})
-		*index++
+		b.index++
 	}
-	builder.Reset()
+	b.current.Reset()
+}
+
+// finish implements lineParser
+func (b *commandBuilder) finish() {
+	if b.current.Len() > 0 {
+		b.flush()
+	}
+}
+
+// zshParser parses both the EXTENDED_HISTORY and plain zsh formats
+type zshParser struct {
+	commandBuilder
+	continued bool // previous line ended with a backslash
 }
 
 // ParseZshHistory converts raw Zsh history lines into commands.
+// Both the EXTENDED_HISTORY format (": <start>:<elapsed>;cmd") and the plain
+// one-command-per-line format are understood, including files that mix the two.
+// A line ending in a backslash continues onto the next line, which is how zsh
+// stores multi-line commands in either format.
 func ParseZshHistory(rawLines []string) []Command {
-	var (
-		commands         []Command
-		current          strings.Builder
-		index            = 1
-		currentTimestamp time.Time
-	)
+	return ParseHistory(ShellZsh, rawLines)
+}
 
-	for _, line := range rawLines {
-		if zshHistoryPrefix.MatchString(line) {
-			if current.Len() > 0 {
-				flushCurrent(&commands, &current, &index, currentTimestamp)
-			}
-
-			ts, cmd := extractMetadataAndCommand(line)
-			currentTimestamp = ts
-			if cmd != "" {
-				current.WriteString(cmd)
-			}
-		} else {
-			if current.Len() > 0 {
-				current.WriteString("\n")
-			}
-			current.WriteString(line)
-		}
-	}
+func (p *zshParser) parseLine(line string) bool {
+	line = unmetafy(line)
 
-	if current.Len() > 0 {
-		flushCurrent(&commands, &current, &index, currentTimestamp)
+	startsEntry := !p.continued
+	var text string
+	switch {
+	case p.continued:
+		p.current.WriteString("\n")
+		text = line
+	case zshHistoryPrefix.MatchString(line):
+		p.finish()
+		p.timestamp, p.duration, text = extractMetadataAndCommand(line)
+	default:
+		// Plain history line: a new command without metadata
+		p.finish()
+		p.timestamp, p.duration = time.Time{}, 0
+		text = line
 	}
-	return commands
+
+	text, p.continued = strings.CutSuffix(text, "\\")
+	p.current.WriteString(text)
+	return startsEntry
 }
 
-func extractMetadataAndCommand(line string) (time.Time, string) {
+// extractMetadataAndCommand splits an extended history line ": <start>:<elapsed>;cmd"
+// into its start time, elapsed runtime and command text.
+func extractMetadataAndCommand(line string) (time.Time, time.Duration, string) {
 	parts := strings.SplitN(line, ";", 2)
 	if len(parts) != 2 {
-		return time.Time{}, ""
+		return time.Time{}, 0, ""
 	}
 
 	meta := strings.TrimPrefix(parts[0], ": ")
 	metaParts := strings.Split(meta, ":")
 	if len(metaParts) == 0 {
-		return time.Time{}, parts[1]
+		return time.Time{}, 0, parts[1]
 	}
 
 	epoch, err := strconv.ParseInt(metaParts[0], 10, 64)
 	if err != nil {
-		return time.Time{}, parts[1]
+		return time.Time{}, 0, parts[1]
+	}
+
+	var elapsed time.Duration
+	if len(metaParts) > 1 {
+		if seconds, err := strconv.ParseInt(metaParts[1], 10, 64); err == nil {
+			elapsed = time.Duration(seconds) * time.Second
+		}
+	}
+
+	return time.Unix(epoch, 0), elapsed, parts[1]
+}
+
+// unmetafy reverses zsh's meta escaping so the line holds the bytes the user typed
+func unmetafy(line string) string {
+	if strings.IndexByte(line, zshMeta) == -1 {
+		return line
 	}
 
-	return time.Unix(epoch, 0), parts[1]
+	buf := make([]byte, 0, len(line))
+	for i := 0; i < len(line); i++ {
+		if line[i] == zshMeta && i+1 < len(line) {
+			i++
+			buf = append(buf, line[i]^0x20)
+			continue
+		}
+		buf = append(buf, line[i])
+	}
+	return string(buf)
 }
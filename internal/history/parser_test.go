@@ -0,0 +1,191 @@
+package history
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseBashHistory(t *testing.T) {
+	tests := []struct {
+		name       string
+		lines      []string
+		wantTexts  []string
+		wantEpochs []int64 // 0 means no timestamp
+	}{
+		{
+			name:       "plain lines",
+			lines:      []string{"ls -la", "", "git status"},
+			wantTexts:  []string{"ls -la", "git status"},
+			wantEpochs: []int64{0, 0},
+		},
+		{
+			name:       "timestamped entries",
+			lines:      []string{"#1700000000", "ls -la", "#1700000100", "git status"},
+			wantTexts:  []string{"ls -la", "git status"},
+			wantEpochs: []int64{1700000000, 1700000100},
+		},
+		{
+			name:       "multi-line command between timestamps",
+			lines:      []string{"#1700000000", "for f in *; do", "echo $f", "done", "#1700000100", "pwd"},
+			wantTexts:  []string{"for f in *; do\necho $f\ndone", "pwd"},
+			wantEpochs: []int64{1700000000, 1700000100},
+		},
+		{
+			name:       "timestamps enabled part way through",
+			lines:      []string{"make", "make test", "#1700000000", "go build ./..."},
+			wantTexts:  []string{"make", "make test", "go build ./..."},
+			wantEpochs: []int64{0, 0, 1700000000},
+		},
+		{
+			name:       "comment is not a timestamp",
+			lines:      []string{"#not-a-timestamp", "echo hi"},
+			wantTexts:  []string{"#not-a-timestamp", "echo hi"},
+			wantEpochs: []int64{0, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseBashHistory(tt.lines)
+			assertCommands(t, got, tt.wantTexts, tt.wantEpochs)
+		})
+	}
+}
+
+// assertCommands checks the text, timestamp and 1-based index of parsed commands
+func assertCommands(t *testing.T, got []Command, wantTexts []string, wantEpochs []int64) {
+	t.Helper()
+	if len(got) != len(wantTexts) {
+		t.Fatalf("got %d commands %v, want %d", len(got), got, len(wantTexts))
+	}
+	for i, cmd := range got {
+		if cmd.Index != i+1 {
+			t.Errorf("command %d: Index = %d, want %d", i, cmd.Index, i+1)
+		}
+		if cmd.Text != wantTexts[i] {
+			t.Errorf("command %d: Text = %q, want %q", i, cmd.Text, wantTexts[i])
+		}
+		var want time.Time
+		if wantEpochs[i] != 0 {
+			want = time.Unix(wantEpochs[i], 0)
+		}
+		if !cmd.Timestamp.Equal(want) {
+			t.Errorf("command %d: Timestamp = %v, want %v", i, cmd.Timestamp, want)
+		}
+	}
+}
+
+func TestParseFishHistory(t *testing.T) {
+	lines := []string{
+		"- cmd: ls -la",
+		"  when: 1700000000",
+		"- cmd: cd /tmp",
+		"  when: 1700000100",
+		"  paths:",
+		"    - /tmp",
+		`- cmd: for f in *\n  echo $f\nend`,
+		"  when: 1700000200",
+		`- cmd: echo C:\\Users`,
+		"- cmd: echo no-when",
+	}
+
+	got := ParseFishHistory(lines)
+	assertCommands(t, got,
+		[]string{"ls -la", "cd /tmp", "for f in *\n  echo $f\nend", `echo C:\Users`, "echo no-when"},
+		[]int64{1700000000, 1700000100, 1700000200, 0, 0},
+	)
+}
+
+func TestUnmetafy(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "ascii untouched", in: "ls -la", want: "ls -la"},
+		// "é" is 0xC3 0xA9; zsh leaves it alone because neither byte is in 0x83-0xA2
+		{name: "bytes outside meta range", in: "cd caf\xc3\xa9", want: "cd café"},
+		// "ơ" is 0xC6 0xA1 -> 0xA1 is metafied
+		{name: "metafied continuation byte", in: "echo \xc6\x83\x81", want: "echo ơ"},
+		// "日" is 0xE6 0x97 0xA5 -> 0x97 is metafied
+		{name: "cjk", in: "cat \xe6\x83\xb7\xa5.txt", want: "cat 日.txt"},
+		// "😀" is 0xF0 0x9F 0x98 0x80 -> 0x9F and 0x98 are metafied
+		{name: "emoji", in: "echo \xf0\x83\xbf\x83\xb8\x80", want: "echo 😀"},
+		{name: "trailing meta byte kept", in: "abc\x83", want: "abc\x83"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unmetafy(tt.in); got != tt.want {
+				t.Errorf("unmetafy(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseZshHistoryUnmetafies(t *testing.T) {
+	got := ParseZshHistory([]string{": 1700000000:0;cat \xe6\x83\xb7\xa5.txt"})
+	assertCommands(t, got, []string{"cat 日.txt"}, []int64{1700000000})
+}
+
+func TestParseZshHistoryDuration(t *testing.T) {
+	got := ParseZshHistory([]string{
+		": 1700000000:0;ls",
+		": 1700000100:95;make build",
+	})
+	assertCommands(t, got, []string{"ls", "make build"}, []int64{1700000000, 1700000100})
+
+	wantDurations := []time.Duration{0, 95 * time.Second}
+	for i, cmd := range got {
+		if cmd.Duration != wantDurations[i] {
+			t.Errorf("command %d: Duration = %v, want %v", i, cmd.Duration, wantDurations[i])
+		}
+	}
+}
+
+func TestParseZshHistoryFormats(t *testing.T) {
+	tests := []struct {
+		name       string
+		lines      []string
+		wantTexts  []string
+		wantEpochs []int64
+	}{
+		{
+			name:       "plain format",
+			lines:      []string{"ls -la", "git status", "", "make test"},
+			wantTexts:  []string{"ls -la", "git status", "make test"},
+			wantEpochs: []int64{0, 0, 0},
+		},
+		{
+			name:       "plain multi-line command",
+			lines:      []string{"for f in *; do\\", "echo $f\\", "done", "pwd"},
+			wantTexts:  []string{"for f in *; do\necho $f\ndone", "pwd"},
+			wantEpochs: []int64{0, 0},
+		},
+		{
+			name:       "extended multi-line command",
+			lines:      []string{": 1700000000:0;if true; then\\", "echo yes\\", "fi", ": 1700000100:0;pwd"},
+			wantTexts:  []string{"if true; then\necho yes\nfi", "pwd"},
+			wantEpochs: []int64{1700000000, 1700000100},
+		},
+		{
+			name:       "continuation line that looks like metadata",
+			lines:      []string{": 1700000000:0;cat <<EOF\\", ": 1700000050:0;not a new entry\\", "EOF"},
+			wantTexts:  []string{"cat <<EOF\n: 1700000050:0;not a new entry\nEOF"},
+			wantEpochs: []int64{1700000000},
+		},
+		{
+			name:       "mixed formats after toggling EXTENDED_HISTORY",
+			lines:      []string{"ls", "cd /tmp", ": 1700000000:0;git status", ": 1700000100:2;make", "echo plain again"},
+			wantTexts:  []string{"ls", "cd /tmp", "git status", "make", "echo plain again"},
+			wantEpochs: []int64{0, 0, 1700000000, 1700000100, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseZshHistory(tt.lines)
+			assertCommands(t, got, tt.wantTexts, tt.wantEpochs)
+		})
+	}
+}
@@ -0,0 +1,371 @@
+package history
+
+import (
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// MatchMode decides how plain query terms are matched
+type MatchMode int
+
+const (
+	// MatchExact matches plain terms as case-insensitive substrings
+	MatchExact MatchMode = iota
+	// MatchFuzzy matches plain terms fuzzily
+	MatchFuzzy
+)
+
+// termKind is how a single query term is matched
+type termKind int
+
+const (
+	termFuzzy  termKind = iota
+	termExact           // Substring
+	termPrefix          // ^term
+	termSuffix          // term$
+	termEqual           // ^term$
+	termField           // cmd:term and the other commandField selectors
+)
+
+// commandField is the part of a parsed command line a selector term compares against
+type commandField int
+
+const (
+	fieldProgram    commandField = iota // cmd:
+	fieldSubcommand                     // sub:
+	fieldArg                            // arg:
+	fieldPipe                           // pipe:
+)
+
+// fieldSelectors maps the selector prefixes of terms to their fields
+var fieldSelectors = map[string]commandField{
+	"cmd":  fieldProgram,
+	"sub":  fieldSubcommand,
+	"arg":  fieldArg,
+	"pipe": fieldPipe,
+}
+
+// queryTerm is one term of a Query. Its text is case folded unless the term is case sensitive.
+type queryTerm struct {
+	kind      termKind
+	field     commandField // For termField
+	inverse   bool
+	sensitive bool
+	text      string
+	runes     []rune
+}
+
+// Query is a search query in fzf's extended syntax. Space separated terms must all
+// match, and terms joined by a "|" term form a group of which any one has to match.
+//
+//	foo     plain term, matched according to the MatchMode
+//	'foo    exact term, or a fuzzy one in MatchExact mode
+//	^foo    prefix
+//	foo$    suffix
+//	^foo$   whole command
+//	!foo    must not contain foo; combines with ^ and $, and !'foo excludes fuzzy matches
+//
+// Selectors compare a whole word of the command line, as split by ParseCommandLine,
+// so "cmd:git" doesn't match "digit". They can be negated with ! as well.
+//
+//	cmd:git      program of a command that isn't piped into, with or without its directory
+//	sub:compose  subcommand, the first argument that isn't an option
+//	arg:--force  any argument
+//	pipe:grep    program of a command that is piped into
+//
+// A backslash escapes a space that belongs to a term. In CaseSmart mode each term
+// decides on its own, so only terms containing an uppercase letter are case sensitive.
+type Query struct {
+	groups [][]queryTerm
+	scored bool // Matches are ranked, so exact terms need a score too
+}
+
+// ParseQuery parses input in the extended syntax, matching plain terms according to mode
+// and telling case apart according to caseMode
+func ParseQuery(input string, mode MatchMode, caseMode CaseMode) *Query {
+	q := &Query{scored: mode == MatchFuzzy}
+	joinNext := false
+	for _, token := range splitQuery(input) {
+		if token == "|" {
+			joinNext = len(q.groups) > 0
+			continue
+		}
+		term, ok := parseTerm(token, mode, caseMode)
+		if !ok {
+			continue
+		}
+		if joinNext {
+			last := len(q.groups) - 1
+			q.groups[last] = append(q.groups[last], term)
+		} else {
+			q.groups = append(q.groups, []queryTerm{term})
+		}
+		joinNext = false
+	}
+	return q
+}
+
+// splitQuery splits input on spaces that aren't escaped with a backslash
+func splitQuery(input string) []string {
+	var tokens []string
+	var token strings.Builder
+	for i := 0; i < len(input); i++ {
+		switch {
+		case input[i] == '\\' && i+1 < len(input) && input[i+1] == ' ':
+			token.WriteByte(' ')
+			i++
+		case input[i] == ' ':
+			if token.Len() > 0 {
+				tokens = append(tokens, token.String())
+				token.Reset()
+			}
+		default:
+			token.WriteByte(input[i])
+		}
+	}
+	if token.Len() > 0 {
+		tokens = append(tokens, token.String())
+	}
+	return tokens
+}
+
+// parseTerm parses a single term the way fzf does. It reports false for a term
+// that is nothing but operators.
+func parseTerm(text string, mode MatchMode, caseMode CaseMode) (queryTerm, bool) {
+	term := queryTerm{kind: termExact}
+	if mode == MatchFuzzy {
+		term.kind = termFuzzy
+	}
+
+	if strings.HasPrefix(text, "!") {
+		term.inverse = true
+		term.kind = termExact
+		text = text[1:]
+	}
+	key, value, found := strings.Cut(text, ":")
+	if field, ok := fieldSelectors[key]; ok && found {
+		// The value of a selector is taken literally
+		term.kind, term.field = termField, field
+		text = value
+	} else {
+		text = parseOperators(&term, text, mode)
+	}
+
+	if text == "" {
+		return queryTerm{}, false
+	}
+	term.sensitive = caseMode.sensitive(text)
+	if !term.sensitive {
+		text = foldString(text)
+	}
+	term.text = text
+	term.runes = []rune(term.text)
+	return term, true
+}
+
+// parseOperators sets the kind of term from the $, ' and ^ operators around text
+// and returns text without them
+func parseOperators(term *queryTerm, text string, mode MatchMode) string {
+	if text != "$" && strings.HasSuffix(text, "$") {
+		term.kind = termSuffix
+		text = text[:len(text)-1]
+	}
+	if strings.HasPrefix(text, "'") {
+		// Quoting flips exactness: exact in fuzzy mode, fuzzy in exact mode
+		if mode == MatchFuzzy && !term.inverse {
+			term.kind = termExact
+		} else {
+			term.kind = termFuzzy
+		}
+		text = text[1:]
+	} else if strings.HasPrefix(text, "^") {
+		if term.kind == termSuffix {
+			term.kind = termEqual
+		} else {
+			term.kind = termPrefix
+		}
+		text = text[1:]
+	}
+	return text
+}
+
+// Empty reports whether the query has no terms and so matches every command
+func (q *Query) Empty() bool {
+	return len(q.groups) == 0
+}
+
+// MatchPositions returns the sorted rune positions of text matched by the positive terms
+// of q, for highlighting, or nil if text doesn't match
+func (q *Query) MatchPositions(text string) []int {
+	var a aligner
+	_, positions, _ := q.match(text, foldString(text), &a, true)
+	return positions
+}
+
+// match reports whether text, whose case folded form is folded, satisfies every group
+// of q. It returns the summed score of the matched positive terms and, if wantPositions
+// is set or a fuzzy term had to compute them anyway, the positions they matched.
+func (q *Query) match(text, folded string, a *aligner, wantPositions bool) (int, []int, bool) {
+	total := 0
+	var positions []int
+	for _, group := range q.groups {
+		matched := false
+		for _, term := range group {
+			score, termPositions, ok := term.match(text, folded, a, q.scored, wantPositions)
+			if ok == term.inverse {
+				continue
+			}
+			matched = true
+			if !term.inverse {
+				total += score
+				positions = append(positions, termPositions...)
+			}
+			break
+		}
+		if !matched {
+			return 0, nil, false
+		}
+	}
+
+	if len(positions) > 0 {
+		slices.Sort(positions)
+		positions = slices.Compact(positions)
+	}
+	return total, positions, true
+}
+
+// match reports whether the term matches text, ignoring inversion, and returns its score
+// and matched rune positions. Exact terms only compute them when scored and wantPositions are set.
+func (t *queryTerm) match(text, folded string, a *aligner, scored, wantPositions bool) (int, []int, bool) {
+	if t.sensitive {
+		folded = text // Compare against the text as is
+	}
+
+	var start int // Byte offset of the match in folded
+	switch t.kind {
+	case termFuzzy:
+		if !containsInOrder(folded, t.runes) {
+			return 0, nil, false
+		}
+		score, positions := a.align(text, t.runes, t.sensitive)
+		return score, positions, positions != nil
+	case termField:
+		return t.matchField(text, a, scored, wantPositions)
+	case termExact:
+		start = strings.Index(folded, t.text)
+		if start == -1 {
+			return 0, nil, false
+		}
+	case termPrefix:
+		if !strings.HasPrefix(folded, t.text) {
+			return 0, nil, false
+		}
+	case termSuffix:
+		if !strings.HasSuffix(folded, t.text) {
+			return 0, nil, false
+		}
+		start = len(folded) - len(t.text)
+	case termEqual:
+		if folded != t.text {
+			return 0, nil, false
+		}
+	}
+
+	if !scored && !wantPositions {
+		return 0, nil, true
+	}
+
+	first := utf8.RuneCountInString(folded[:start])
+	span := make([]int, len(t.runes))
+	for i := range span {
+		span[i] = first + i
+	}
+
+	score := 0
+	if scored {
+		// Score the first occurrence like an alignment of consecutive runes
+		score = scorePositions(a.bonuses(text, t.sensitive), span)
+	}
+	if !wantPositions {
+		return score, nil, true
+	}
+	if t.kind == termExact {
+		// Highlight every occurrence, not just the first
+		return score, occurrencePositions(folded, t.text), true
+	}
+	return score, span, true
+}
+
+// matchField matches a selector term against the words of text's command line. It scores
+// the first matching word like an exact term and returns the positions of every one.
+func (t *queryTerm) matchField(text string, a *aligner, scored, wantPositions bool) (int, []int, bool) {
+	var spans []Word
+	for _, cmd := range a.commandLine(text) {
+		switch t.field {
+		case fieldProgram, fieldPipe:
+			if cmd.Piped == (t.field == fieldPipe) && (t.equals(cmd.Program.Text) || t.equals(programName(cmd.Program.Text))) {
+				spans = append(spans, cmd.Program)
+			}
+		case fieldSubcommand:
+			if cmd.Subcommand.Text != "" && t.equals(cmd.Subcommand.Text) {
+				spans = append(spans, cmd.Subcommand)
+			}
+		case fieldArg:
+			for _, arg := range cmd.Args {
+				if t.equals(arg.Text) {
+					spans = append(spans, arg)
+				}
+			}
+		}
+		if len(spans) > 0 && !scored && !wantPositions {
+			return 0, nil, true
+		}
+	}
+	if len(spans) == 0 {
+		return 0, nil, false
+	}
+
+	positions := wordPositions(text, spans[0])
+	score := 0
+	if scored {
+		score = scorePositions(a.bonuses(text, t.sensitive), positions)
+	}
+	if !wantPositions {
+		return score, nil, true
+	}
+	for _, span := range spans[1:] {
+		positions = append(positions, wordPositions(text, span)...)
+	}
+	return score, positions, true
+}
+
+// wordPositions returns the rune positions text covers with word
+func wordPositions(text string, word Word) []int {
+	first := utf8.RuneCountInString(text[:word.Start])
+	positions := make([]int, utf8.RuneCountInString(text[word.Start:word.End]))
+	for i := range positions {
+		positions[i] = first + i
+	}
+	return positions
+}
+
+// equals reports whether word is the term's text, ignoring case unless the term is case sensitive
+func (t *queryTerm) equals(word string) bool {
+	if t.sensitive {
+		return word == t.text
+	}
+	return foldString(word) == t.text
+}
+
+// mask returns the charMask bits every matching command's folded text must have.
+// Selector terms count too: unquoting a word only ever drops characters from the text.
+func (q *Query) mask() uint64 {
+	var mask uint64
+	for _, group := range q.groups {
+		if len(group) == 1 && !group[0].inverse {
+			mask |= charMask(foldString(group[0].text))
+		}
+	}
+	return mask
+}
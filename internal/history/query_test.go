@@ -0,0 +1,135 @@
+package history
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		input string
+		mode  MatchMode
+		want  [][]queryTerm
+	}{
+		{"git", MatchFuzzy, [][]queryTerm{{{kind: termFuzzy, text: "git"}}}},
+		{"git", MatchExact, [][]queryTerm{{{kind: termExact, text: "git"}}}},
+		{"'git", MatchFuzzy, [][]queryTerm{{{kind: termExact, text: "git"}}}},
+		{"'git", MatchExact, [][]queryTerm{{{kind: termFuzzy, text: "git"}}}},
+		{"^Git", MatchFuzzy, [][]queryTerm{{{kind: termPrefix, sensitive: true, text: "Git"}}}},
+		{"Git push", MatchExact, [][]queryTerm{{{kind: termExact, sensitive: true, text: "Git"}}, {{kind: termExact, text: "push"}}}},
+		{"go$", MatchFuzzy, [][]queryTerm{{{kind: termSuffix, text: "go"}}}},
+		{"^ls$", MatchFuzzy, [][]queryTerm{{{kind: termEqual, text: "ls"}}}},
+		{"!test", MatchFuzzy, [][]queryTerm{{{kind: termExact, inverse: true, text: "test"}}}},
+		{"!^cd", MatchFuzzy, [][]queryTerm{{{kind: termPrefix, inverse: true, text: "cd"}}}},
+		{"git  push", MatchExact, [][]queryTerm{{{kind: termExact, text: "git"}}, {{kind: termExact, text: "push"}}}},
+		{`git\ push`, MatchExact, [][]queryTerm{{{kind: termExact, text: "git push"}}}},
+		{"py$ | rb$ make", MatchExact, [][]queryTerm{
+			{{kind: termSuffix, text: "py"}, {kind: termSuffix, text: "rb"}},
+			{{kind: termExact, text: "make"}},
+		}},
+		{"| ! ^ '", MatchExact, nil},
+		{"$", MatchFuzzy, [][]queryTerm{{{kind: termFuzzy, text: "$"}}}},
+		{"!cmd:Git", MatchExact, [][]queryTerm{{{kind: termField, field: fieldProgram, inverse: true, sensitive: true, text: "Git"}}}},
+		{"pipe:^grep$", MatchFuzzy, [][]queryTerm{{{kind: termField, field: fieldPipe, text: "^grep$"}}}},
+		{"sub:", MatchExact, nil},
+		{"cmd", MatchExact, [][]queryTerm{{{kind: termExact, text: "cmd"}}}},
+		{"url:x", MatchExact, [][]queryTerm{{{kind: termExact, text: "url:x"}}}},
+	}
+
+	for _, tt := range tests {
+		got := ParseQuery(tt.input, tt.mode, CaseSmart).groups
+		for _, group := range got {
+			for i := range group {
+				group[i].runes = nil
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseQuery(%q) = %+v, want %+v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestQueryMatch(t *testing.T) {
+	tests := []struct {
+		query string
+		mode  MatchMode
+		text  string
+		want  bool
+	}{
+		{"git push", MatchExact, "git push origin", true},
+		{"push git", MatchExact, "git push origin", true},
+		{"git pull", MatchExact, "git push origin", false},
+		{"gt po", MatchFuzzy, "git push origin", true},
+		{"'gt", MatchFuzzy, "git push origin", false},
+		{"^git", MatchExact, "git push", true},
+		{"^push", MatchExact, "git push", false},
+		{"push$", MatchExact, "git push", true},
+		{"^git push$", MatchExact, "git push", true},
+		{"^git\\ push$", MatchExact, "git push", true},
+		{"^git$", MatchExact, "git push", false},
+		{"git !force", MatchExact, "git push --force", false},
+		{"git !force", MatchExact, "git push", true},
+		{"!^cd", MatchFuzzy, "cd /tmp", false},
+		{"py$ | rb$", MatchExact, "ruby app.rb", true},
+		{"py$ | rb$", MatchExact, "node app.js", false},
+		{"app py$ | rb$", MatchFuzzy, "python app.py", true},
+		{"cmd:git", MatchExact, "git push", true},
+		{"cmd:git", MatchExact, "echo digit", false},
+		{"cmd:git", MatchExact, "/usr/bin/git log", true},
+		{"cmd:grep", MatchExact, "cat log | grep error", false},
+		{"pipe:grep", MatchExact, "cat log | grep error", true},
+		{"pipe:cat", MatchExact, "cat log | grep error", false},
+		{"pipe:out", MatchExact, "echo x >| out", false},
+		{"cmd:echo", MatchExact, "echo x >| out", true},
+		{"sub:log", MatchExact, "make > log", false},
+		{"arg:log", MatchExact, "make 2>&1 > log", false},
+		{"cmd:make", MatchExact, "make && make install", true},
+		{"sub:compose", MatchFuzzy, "docker compose up -d", true},
+		{"sub:up", MatchFuzzy, "docker compose up -d", false},
+		{"arg:--force", MatchExact, "git push --force", true},
+		{"arg:--force", MatchExact, "git push --force-with-lease", false},
+		{"arg:force", MatchExact, "git push --force", false},
+		{"arg:hello\\ world", MatchExact, `echo "hello world"`, true},
+		{"cmd:git !arg:--force", MatchExact, "git push --force", false},
+		{"cmd:docker | cmd:podman", MatchExact, "podman ps", true},
+		{"cmd:", MatchExact, "anything", true},
+	}
+
+	for _, tt := range tests {
+		var a aligner
+		_, _, got := ParseQuery(tt.query, tt.mode, CaseSmart).match(tt.text, foldString(tt.text), &a, false)
+		if got != tt.want {
+			t.Errorf("ParseQuery(%q).match(%q) = %v, want %v", tt.query, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestQueryMatchPositions(t *testing.T) {
+	tests := []struct {
+		query string
+		mode  MatchMode
+		text  string
+		want  []int
+	}{
+		{"ab", MatchExact, "ab ab", []int{0, 1, 3, 4}},
+		{"push git", MatchExact, "git push", []int{0, 1, 2, 4, 5, 6, 7}},
+		{"^gi sh$", MatchExact, "git push", []int{0, 1, 6, 7}},
+		{"gp !force", MatchFuzzy, "git push", []int{0, 4}},
+		{"zz | sh", MatchExact, "git push", []int{6, 7}},
+		{"missing", MatchExact, "git push", nil},
+		{"cmd:git", MatchExact, "echo digit && git log", []int{14, 15, 16}},
+		{"arg:a", MatchExact, "ls a 'a' b", []int{3, 5, 6, 7}},
+		{"pipe:wc", MatchFuzzy, "ls | wc -l", []int{5, 6}},
+		{"ls", MatchExact, "日本語 ls", []int{4, 5}},
+		{"straße", MatchExact, "mv Straße STRASSE", []int{3, 4, 5, 6, 7, 8}},
+		{"k", MatchExact, "temp 300\u212a", []int{8}},
+	}
+
+	for _, tt := range tests {
+		got := ParseQuery(tt.query, tt.mode, CaseSmart).MatchPositions(tt.text)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("ParseQuery(%q).MatchPositions(%q) = %v, want %v", tt.query, tt.text, got, tt.want)
+		}
+	}
+}
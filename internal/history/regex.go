@@ -0,0 +1,43 @@
+package history
+
+import (
+	"context"
+	"regexp"
+	"unicode/utf8"
+)
+
+// SearchRegex returns the commands that re matches, in their original order
+func SearchRegex(commands []Command, re *regexp.Regexp) []Command {
+	filtered, _ := SearchRegexContext(context.Background(), commands, re)
+	return filtered
+}
+
+// SearchRegexContext is like SearchRegex but gives up with ctx's error once ctx is done
+func SearchRegexContext(ctx context.Context, commands []Command, re *regexp.Regexp) ([]Command, error) {
+	var filtered []Command
+	for i, cmd := range commands {
+		if i%searchCheckInterval == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if re.MatchString(cmd.Text) {
+			filtered = append(filtered, cmd)
+		}
+	}
+	return filtered, nil
+}
+
+// RegexMatchPositions returns the sorted rune positions of text covered by every
+// match of re, for highlighting. Empty matches cover nothing.
+func RegexMatchPositions(text string, re *regexp.Regexp) []int {
+	var positions []int
+	runeIndex, offset := 0, 0
+	for _, span := range re.FindAllStringIndex(text, -1) {
+		runeIndex += utf8.RuneCountInString(text[offset:span[0]])
+		for range text[span[0]:span[1]] {
+			positions = append(positions, runeIndex)
+			runeIndex++
+		}
+		offset = span[1]
+	}
+	return positions
+}
@@ -0,0 +1,88 @@
+package history
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestSearchRegex(t *testing.T) {
+	commands := []Command{
+		{Text: "git push origin main", Index: 0},
+		{Text: "git pull", Index: 1},
+		{Text: "make test", Index: 2},
+		{Text: "GO TEST ./...", Index: 3},
+	}
+
+	tests := []struct {
+		pattern string
+		want    []int
+	}{
+		{"^git pu(sh|ll)", []int{0, 1}},
+		{"test$", []int{2}},
+		{"go test", []int{3}},
+		{`\./\.\.\.`, []int{3}},
+		{"^$", nil},
+	}
+
+	index := NewIndex(commands)
+	for _, tt := range tests {
+		re, err := CompileRegex(tt.pattern, CaseSmart)
+		if err != nil {
+			t.Fatalf("CompileRegex(%q) error = %v", tt.pattern, err)
+		}
+
+		var got []int
+		for _, cmd := range SearchRegex(commands, re) {
+			got = append(got, cmd.Index)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("SearchRegex(%q) = %v, want %v", tt.pattern, got, tt.want)
+		}
+
+		indexed, err := index.SearchRegex(context.Background(), re)
+		if err != nil {
+			t.Fatalf("Index.SearchRegex(%q) error = %v", tt.pattern, err)
+		}
+		got = nil
+		for _, cmd := range indexed {
+			got = append(got, cmd.Index)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Index.SearchRegex(%q) = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestCompileRegexInvalid(t *testing.T) {
+	for _, pattern := range []string{"git (", "[a-", "*"} {
+		if _, err := CompileRegex(pattern, CaseSmart); err == nil {
+			t.Errorf("CompileRegex(%q) succeeded, want an error", pattern)
+		}
+	}
+}
+
+func TestRegexMatchPositions(t *testing.T) {
+	tests := []struct {
+		text    string
+		pattern string
+		want    []int
+	}{
+		{"git push", "p.sh", []int{4, 5, 6, 7}},
+		{"a-b-c", "[a-c]", []int{0, 2, 4}},
+		{"café fiancée", "é", []int{3, 10}},
+		{"ÉCOLE école", "école", []int{0, 1, 2, 3, 4, 6, 7, 8, 9, 10}},
+		{"ls", "x*", nil},
+		{"ls", "go", nil},
+	}
+
+	for _, tt := range tests {
+		re, err := CompileRegex(tt.pattern, CaseSmart)
+		if err != nil {
+			t.Fatalf("CompileRegex(%q) error = %v", tt.pattern, err)
+		}
+		if got := RegexMatchPositions(tt.text, re); !slices.Equal(got, tt.want) {
+			t.Errorf("RegexMatchPositions(%q, %q) = %v, want %v", tt.text, tt.pattern, got, tt.want)
+		}
+	}
+}
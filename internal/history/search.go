@@ -1,69 +1,64 @@
 package history
 
 import (
-	"sort"
-	"strings"
+	"cmp"
+	"context"
+	"slices"
 )
 
+// searchCheckInterval is how many commands are scanned between checks for cancellation
+const searchCheckInterval = 1024
+
+// SearchExact returns the commands matching input, ignoring case unless input contains
+// an uppercase letter. The input is a Query whose plain terms must be contained in the command.
 func SearchExact(commands []Command, input string) []Command {
-	if strings.TrimSpace(input) == "" {
-		return commands
+	filtered, _ := SearchExactContext(context.Background(), commands, input, CaseSmart)
+	return filtered
+}
+
+// SearchExactContext is like SearchExact with an explicit CaseMode, but gives up with
+// ctx's error once ctx is done
+func SearchExactContext(ctx context.Context, commands []Command, input string, caseMode CaseMode) ([]Command, error) {
+	query := ParseQuery(input, MatchExact, caseMode)
+	if query.Empty() {
+		return commands, nil
 	}
 
-	inputLower := strings.ToLower(input)
 	var filtered []Command
-	for _, cmd := range commands {
-		if strings.Contains(strings.ToLower(cmd.Text), inputLower) {
+	var a aligner
+	for i, cmd := range commands {
+		if i%searchCheckInterval == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if _, _, ok := query.match(cmd.Text, foldString(cmd.Text), &a, false); ok {
 			filtered = append(filtered, cmd)
 		}
 	}
-	return filtered
+	return filtered, nil
 }
 
 // FuzzyMatch represents a command with its fuzzy match score and positions
 type FuzzyMatch struct {
 	Command   Command
 	Score     int
-	Positions []int // Character positions that matched the query
+	Positions []int // Rune positions that matched the query
 }
 
 // FuzzyMatchResult represents a command with its fuzzy match positions
 type FuzzyMatchResult struct {
 	Command   Command
-	Positions []int // Character positions that matched the query
+	Positions []int // Rune positions that matched the query
 }
 
-// SearchFuzzy performs fuzzy matching on commands and returns them sorted by relevance
+// SearchFuzzy performs fuzzy matching on commands and returns them sorted by relevance.
+// The input is a Query whose plain terms are matched fuzzily, with smart case.
 func SearchFuzzy(commands []Command, input string) []Command {
-	if strings.TrimSpace(input) == "" {
+	query := ParseQuery(input, MatchFuzzy, CaseSmart)
+	if query.Empty() {
 		return commands
 	}
 
-	inputLower := strings.ToLower(input)
-	var matches []FuzzyMatch
-
-	for _, cmd := range commands {
-		positions := findFuzzyMatchPositions(strings.ToLower(cmd.Text), inputLower)
-		if len(positions) == 0 {
-			continue
-		}
-		score := calculateFuzzyScoreWithPositions(cmd.Text, input, inputLower, positions)
-		if score > 0 {
-			matches = append(matches, FuzzyMatch{
-				Command:   cmd,
-				Score:     score,
-				Positions: positions,
-			})
-		}
-	}
-
-	// Sort by score (higher is better), then by index (lower is better for same score)
-	sort.Slice(matches, func(i, j int) bool {
-		if matches[i].Score != matches[j].Score {
-			return matches[i].Score > matches[j].Score
-		}
-		return matches[i].Command.Index < matches[j].Command.Index
-	})
+	matches, _ := fuzzyMatches(context.Background(), commands, query)
 
 	// Extract commands from sorted matches
 	result := make([]Command, len(matches))
@@ -76,44 +71,61 @@ func SearchFuzzy(commands []Command, input string) []Command {
 
 // SearchFuzzyWithPositions performs fuzzy matching and returns commands with their match positions
 func SearchFuzzyWithPositions(commands []Command, input string) []FuzzyMatchResult {
-	if strings.TrimSpace(input) == "" {
-		result := make([]FuzzyMatchResult, len(commands))
-		for i, cmd := range commands {
-			result[i] = FuzzyMatchResult{
-				Command:   cmd,
-				Positions: []int{},
-			}
-		}
-		return result
+	result, _ := SearchFuzzyWithPositionsContext(context.Background(), commands, input, CaseSmart)
+	return result
+}
+
+// SearchFuzzyWithPositionsContext is like SearchFuzzyWithPositions with an explicit CaseMode,
+// but gives up with ctx's error once ctx is done
+func SearchFuzzyWithPositionsContext(ctx context.Context, commands []Command, input string, caseMode CaseMode) ([]FuzzyMatchResult, error) {
+	query := ParseQuery(input, MatchFuzzy, caseMode)
+	if query.Empty() {
+		return unmatchedResults(commands), nil
 	}
 
-	inputLower := strings.ToLower(input)
+	matches, err := fuzzyMatches(ctx, commands, query)
+	if err != nil {
+		return nil, err
+	}
+	return fuzzyResults(matches), nil
+}
+
+// fuzzyMatches matches query against every command and returns the matches sorted by relevance
+func fuzzyMatches(ctx context.Context, commands []Command, query *Query) ([]FuzzyMatch, error) {
 	var matches []FuzzyMatch
+	var a aligner
 
-	for _, cmd := range commands {
-		positions := findFuzzyMatchPositions(strings.ToLower(cmd.Text), inputLower)
-		if len(positions) == 0 {
-			continue
+	for i, cmd := range commands {
+		if i%searchCheckInterval == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
 		}
-		score := calculateFuzzyScoreWithPositions(cmd.Text, input, inputLower, positions)
-		if score > 0 {
-			matches = append(matches, FuzzyMatch{
-				Command:   cmd,
-				Score:     score,
-				Positions: positions,
-			})
+		score, positions, ok := query.match(cmd.Text, foldString(cmd.Text), &a, true)
+		if !ok {
+			continue
 		}
+		matches = append(matches, FuzzyMatch{
+			Command:   cmd,
+			Score:     score,
+			Positions: positions,
+		})
 	}
 
-	// Sort by score (higher is better), then by index (lower is better for same score)
-	sort.Slice(matches, func(i, j int) bool {
-		if matches[i].Score != matches[j].Score {
-			return matches[i].Score > matches[j].Score
+	sortFuzzyMatches(matches)
+	return matches, nil
+}
+
+// sortFuzzyMatches sorts by score (higher is better), then by index (lower is better for same score)
+func sortFuzzyMatches(matches []FuzzyMatch) {
+	slices.SortFunc(matches, func(a, b FuzzyMatch) int {
+		if a.Score != b.Score {
+			return cmp.Compare(b.Score, a.Score)
 		}
-		return matches[i].Command.Index < matches[j].Command.Index
+		return cmp.Compare(a.Command.Index, b.Command.Index)
 	})
+}
 
-	// Extract commands with positions from sorted matches
+// fuzzyResults converts sorted matches to the results returned by fuzzy searches
+func fuzzyResults(matches []FuzzyMatch) []FuzzyMatchResult {
 	result := make([]FuzzyMatchResult, len(matches))
 	for i, match := range matches {
 		result[i] = FuzzyMatchResult{
@@ -121,94 +133,35 @@ func SearchFuzzyWithPositions(commands []Command, input string) []FuzzyMatchResu
 			Positions: match.Positions,
 		}
 	}
-
 	return result
 }
 
-// calculateFuzzyScore calculates a relevance score for fuzzy matching
-// Returns 0 if the query doesn't match, or a positive score indicating match quality
-// This is a wrapper that computes positions internally for backward compatibility
-func calculateFuzzyScore(text, query, queryLower string) int {
-	textLower := strings.ToLower(text)
-	matchPositions := findFuzzyMatchPositions(textLower, queryLower)
-	if len(matchPositions) == 0 {
-		return 0
-	}
-	return calculateFuzzyScoreWithPositions(text, query, queryLower, matchPositions)
-}
-
-// calculateFuzzyScoreWithPositions calculates a relevance score for fuzzy matching using pre-computed positions
-func calculateFuzzyScoreWithPositions(text, query, queryLower string, matchPositions []int) int {
-	// Base score: all characters matched
-	score := 1000
-
-	// Bonus for consecutive matches (adjacent characters in query are adjacent in text)
-	consecutiveBonus := 0
-	for i := 1; i < len(matchPositions); i++ {
-		if matchPositions[i] == matchPositions[i-1]+1 {
-			consecutiveBonus += 50
-		}
-	}
-	score += consecutiveBonus
-
-	// Bonus for matches at the start of the text
-	startBonus := 0
-	if matchPositions[0] == 0 {
-		startBonus = 100
-	} else if matchPositions[0] < 5 {
-		startBonus = 50 - (matchPositions[0] * 10)
-	}
-	score += startBonus
-
-	// Bonus for case-sensitive matches
-	caseBonus := 0
-	for i, pos := range matchPositions {
-		if text[pos] == query[i] {
-			caseBonus += 5
+// unmatchedResults wraps commands as fuzzy results without match positions, for an empty query
+func unmatchedResults(commands []Command) []FuzzyMatchResult {
+	result := make([]FuzzyMatchResult, len(commands))
+	for i, cmd := range commands {
+		result[i] = FuzzyMatchResult{
+			Command:   cmd,
+			Positions: []int{},
 		}
 	}
-	score += caseBonus
-
-	// Penalty for spread (distance between first and last match)
-	spread := matchPositions[len(matchPositions)-1] - matchPositions[0]
-	if spread > len(query) {
-		penalty := (spread - len(query)) * 2
-		score -= penalty
-	}
-
-	// Ensure score is positive
-	if score < 1 {
-		score = 1
-	}
+	return result
+}
 
+// calculateFuzzyScore calculates a relevance score for fuzzy matching
+// Returns 0 if the query doesn't match, or a positive score indicating match quality
+func calculateFuzzyScore(text, query string) int {
+	score, _ := fuzzyAlign(text, []rune(foldString(query)))
 	return score
 }
 
-// findFuzzyMatchPositions finds positions where each query character appears in order
+// findFuzzyMatchPositions finds the rune positions of the best placement of query in text,
+// comparing case-insensitively.
 // Returns empty slice if not all characters can be matched in order
 func findFuzzyMatchPositions(text, query string) []int {
-	if len(query) == 0 {
-		return []int{}
-	}
-
-	positions := make([]int, 0, len(query))
-	textIndex := 0
-
-	for _, queryChar := range query {
-		found := false
-		for textIndex < len(text) {
-			if text[textIndex] == byte(queryChar) {
-				positions = append(positions, textIndex)
-				textIndex++
-				found = true
-				break
-			}
-			textIndex++
-		}
-		if !found {
-			return []int{} // Not all characters matched
-		}
+	_, positions := fuzzyAlign(text, []rune(foldString(query)))
+	if positions == nil {
+		return []int{} // Not all characters matched
 	}
-
 	return positions
 }
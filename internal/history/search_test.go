@@ -1,6 +1,9 @@
 package history
 
 import (
+	"context"
+	"errors"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -48,6 +51,7 @@ func TestSearchFuzzy(t *testing.T) {
 	tests := []struct {
 		name     string
 		query    string
+		caseMode CaseMode // Searched with SearchFuzzy's smart case if empty
 		expected []string
 	}{
 		{
@@ -68,6 +72,7 @@ func TestSearchFuzzy(t *testing.T) {
 		{
 			name:     "fuzzy match - case insensitive",
 			query:    "NPM",
+			caseMode: CaseInsensitive,
 			expected: []string{"npm install react"},
 		},
 		{
@@ -90,6 +95,13 @@ func TestSearchFuzzy(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			result := SearchFuzzy(cmds, tt.query)
+			if tt.caseMode != "" {
+				matches, _ := SearchFuzzyWithPositionsContext(context.Background(), cmds, tt.query, tt.caseMode)
+				result = nil
+				for _, match := range matches {
+					result = append(result, match.Command)
+				}
+			}
 
 			if tt.query == "" {
 				// Empty query should return all commands
@@ -175,6 +187,35 @@ func TestSearchFuzzyScoring(t *testing.T) {
 	}
 }
 
+func TestSearchFuzzySmartCase(t *testing.T) {
+	cmds := []Command{
+		{Index: 1, Text: "npm install react"},
+		{Index: 2, Text: "docker run -it Ubuntu"},
+		{Index: 3, Text: "NPM_TOKEN=x npm publish"},
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"ubuntu", []string{"docker run -it Ubuntu"}},
+		{"NPM", []string{"NPM_TOKEN=x npm publish"}},
+		{"npm", []string{"npm install react", "NPM_TOKEN=x npm publish"}},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, cmd := range SearchFuzzy(cmds, tt.query) {
+			got = append(got, cmd.Text)
+		}
+		slices.Sort(got)
+		want := slices.Sorted(slices.Values(tt.want))
+		if !slices.Equal(got, want) {
+			t.Errorf("SearchFuzzy(%q) = %q, want %q", tt.query, got, want)
+		}
+	}
+}
+
 func TestCalculateFuzzyScore(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -210,8 +251,7 @@ func TestCalculateFuzzyScore(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			queryLower := strings.ToLower(tt.query)
-			score := calculateFuzzyScore(tt.text, tt.query, queryLower)
+			score := calculateFuzzyScore(tt.text, tt.query)
 
 			if tt.expected == 0 {
 				if score != 0 {
@@ -257,6 +297,48 @@ func TestFindFuzzyMatchPositions(t *testing.T) {
 			query:    "gpm",
 			expected: []int{0, 4, 16}, // g at 0, p at 4, m at 16
 		},
+		{
+			name:     "positions are rune indices",
+			text:     "echo héllo wörld",
+			query:    "éw",
+			expected: []int{6, 11},
+		},
+		{
+			name:     "non-ASCII case folding",
+			text:     "cp ÉCOLE.txt /tmp",
+			query:    "école",
+			expected: []int{3, 4, 5, 6, 7},
+		},
+		{
+			name:     "Greek final sigma",
+			text:     "echo ΟΔΥΣΣΕΥΣ",
+			query:    "οδυσσευς",
+			expected: []int{5, 6, 7, 8, 9, 10, 11, 12},
+		},
+		{
+			name:     "prefers word starts over first occurrence",
+			text:     "git checkout origin",
+			query:    "gco",
+			expected: []int{0, 4, 13},
+		},
+		{
+			name:     "prefers consecutive match after path separator",
+			text:     "cd /usr/lib/local",
+			query:    "lo",
+			expected: []int{12, 13},
+		},
+		{
+			name:     "prefers camelCase humps",
+			text:     "make fooBarBaz",
+			query:    "fbb",
+			expected: []int{5, 8, 11},
+		},
+		{
+			name:     "prefers long option after dashes",
+			text:     "git clean -fdx --dry-run",
+			query:    "dr",
+			expected: []int{17, 18},
+		},
 	}
 
 	for _, tt := range tests {
@@ -279,3 +361,56 @@ func TestFindFuzzyMatchPositions(t *testing.T) {
 		})
 	}
 }
+
+func TestSearchUnicodeCaseInsensitive(t *testing.T) {
+	cmds := []Command{
+		{Index: 1, Text: "cat RÉSUMÉ.md"},
+		{Index: 2, Text: "cat resume.md"},
+	}
+
+	if got := SearchExact(cmds, "résumé"); len(got) != 1 || got[0].Index != 1 {
+		t.Errorf("SearchExact(résumé) = %v, want only command 1", got)
+	}
+
+	results := SearchFuzzyWithPositions(cmds, "rsmé")
+	if len(results) != 1 || results[0].Command.Index != 1 {
+		t.Fatalf("SearchFuzzyWithPositions(rsmé) = %v, want only command 1", results)
+	}
+	if want := []int{4, 6, 8, 9}; !slices.Equal(results[0].Positions, want) {
+		t.Errorf("positions = %v, want %v", results[0].Positions, want)
+	}
+}
+
+func TestSearchFuzzyRanksWordStarts(t *testing.T) {
+	cmds := []Command{
+		{Index: 1, Text: "gecko"},
+		{Index: 2, Text: "git checkout origin"},
+	}
+
+	result := SearchFuzzy(cmds, "gco")
+	if len(result) != 2 || result[0].Index != 2 {
+		t.Errorf("SearchFuzzy(gco) = %v, want command 2 first", result)
+	}
+}
+
+func TestFuzzyAlignFallback(t *testing.T) {
+	// Too large for the alignment matrix: positions come from the greedy scan
+	text := "a" + strings.Repeat(" x", maxAlignCells) + " b"
+	score, positions := fuzzyAlign(text, []rune("ab"))
+	if score <= 0 || !slices.Equal(positions, []int{0, len(text) - 1}) {
+		t.Errorf("fuzzyAlign fallback = %d, %v", score, positions)
+	}
+}
+
+func TestSearchContextCancelled(t *testing.T) {
+	cmds := []Command{{Index: 1, Text: "git status"}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := SearchExactContext(ctx, cmds, "git", CaseSmart); !errors.Is(err, context.Canceled) {
+		t.Errorf("SearchExactContext error = %v, want context.Canceled", err)
+	}
+	if _, err := SearchFuzzyWithPositionsContext(ctx, cmds, "git", CaseSmart); !errors.Is(err, context.Canceled) {
+		t.Errorf("SearchFuzzyWithPositionsContext error = %v, want context.Canceled", err)
+	}
+}
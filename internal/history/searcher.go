@@ -0,0 +1,188 @@
+package history
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Searcher searches an Index and remembers the results of recent queries. A query that
+// extends an earlier one, like "git co" after "git c", only re-filters the earlier
+// results, and going back to an earlier query returns its results straight away.
+//
+// Only the results of the current query's prefixes are kept. A Searcher may be used
+// from several goroutines; the slices it returns are shared and must not be modified.
+type Searcher struct {
+	index *Index
+
+	mu    sync.Mutex
+	cache map[searchCacheKey]*cachedSearch
+}
+
+// searchCacheKey identifies a search by its modes and query as typed
+type searchCacheKey struct {
+	fuzzy    bool
+	caseMode CaseMode
+	query    string
+}
+
+// cachedSearch holds the results of one search
+type cachedSearch struct {
+	positions []int32 // Index positions of the matches in history order
+	exact     []Command
+	fuzzy     []FuzzyMatchResult
+}
+
+// NewSearcher returns a Searcher over index
+func NewSearcher(index *Index) *Searcher {
+	return &Searcher{
+		index: index,
+		cache: make(map[searchCacheKey]*cachedSearch),
+	}
+}
+
+// Append returns a Searcher over the index with commands appended; the results
+// remembered by s no longer apply to it
+func (s *Searcher) Append(commands ...Command) *Searcher {
+	return NewSearcher(s.index.Append(commands...))
+}
+
+// SearchExact is like Index.SearchExact, reusing the results of earlier queries
+func (s *Searcher) SearchExact(ctx context.Context, input string, caseMode CaseMode) ([]Command, error) {
+	query := ParseQuery(input, MatchExact, caseMode)
+	if query.Empty() {
+		return s.index.commands, nil
+	}
+
+	key := searchCacheKey{caseMode: caseMode, query: input}
+	cached, candidates := s.candidates(key, query)
+	if cached != nil {
+		return cached.exact, nil
+	}
+
+	positions, err := s.index.exactPositions(ctx, query, candidates)
+	if err != nil {
+		return nil, err
+	}
+	results := s.index.commandsAt(positions)
+	s.store(key, &cachedSearch{positions: positions, exact: results})
+	return results, nil
+}
+
+// SearchFuzzyWithPositions is like Index.SearchFuzzyWithPositions, reusing the results of earlier queries
+func (s *Searcher) SearchFuzzyWithPositions(ctx context.Context, input string, caseMode CaseMode) ([]FuzzyMatchResult, error) {
+	query := ParseQuery(input, MatchFuzzy, caseMode)
+	if query.Empty() {
+		return unmatchedResults(s.index.commands), nil
+	}
+
+	key := searchCacheKey{fuzzy: true, caseMode: caseMode, query: input}
+	cached, candidates := s.candidates(key, query)
+	if cached != nil {
+		return cached.fuzzy, nil
+	}
+
+	matches, err := s.index.fuzzyMatches(ctx, query, candidates)
+	if err != nil {
+		return nil, err
+	}
+	positions := make([]int32, len(matches))
+	for k, match := range matches {
+		positions[k] = match.pos
+	}
+	results := s.index.fuzzyResults(matches)
+	s.store(key, &cachedSearch{positions: positions, fuzzy: results})
+	return results, nil
+}
+
+// SearchRegex is like Index.SearchRegex. Its results aren't cached, since a longer
+// pattern can match more commands than its prefix.
+func (s *Searcher) SearchRegex(ctx context.Context, re *regexp.Regexp) ([]Command, error) {
+	return s.index.SearchRegex(ctx, re)
+}
+
+// SearchApproximate is like Index.SearchApproximate. Its results aren't cached, since
+// longer tokens may be further from the text than their prefixes.
+func (s *Searcher) SearchApproximate(ctx context.Context, input string, caseMode CaseMode) ([]FuzzyMatchResult, error) {
+	return s.index.SearchApproximate(ctx, input, caseMode)
+}
+
+// candidates returns the cached search for key if there is one. Otherwise it returns
+// the smaller of the index's candidates for query and the matches of the longest cached
+// prefix, or nil if neither narrows the search down.
+func (s *Searcher) candidates(key searchCacheKey, query *Query) (*cachedSearch, []int32) {
+	cached, narrowed := s.lookup(key)
+	if cached != nil {
+		return cached, nil
+	}
+
+	candidates := s.index.queryCandidates(query)
+	if narrowed != nil && (candidates == nil || len(narrowed) < len(candidates)) {
+		candidates = narrowed
+	}
+	return nil, candidates
+}
+
+// lookup returns the cached search for key if there is one. Otherwise it returns the
+// matches of the longest cached prefix of the query, which are the only commands the
+// query can match, or nil if no prefix is cached or the query isn't narrowable.
+func (s *Searcher) lookup(key searchCacheKey) (*cachedSearch, []int32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if cached, ok := s.cache[key]; ok {
+		return cached, nil
+	}
+	query := key.query
+	if !narrowable(query) {
+		return nil, nil
+	}
+	for i := len(query) - 1; i > 0; i-- {
+		if !utf8.RuneStart(query[i]) {
+			continue
+		}
+		prefix := searchCacheKey{fuzzy: key.fuzzy, caseMode: key.caseMode, query: query[:i]}
+		if cached, ok := s.cache[prefix]; ok {
+			return nil, cached.positions
+		}
+	}
+	return nil, nil
+}
+
+// store caches a search and forgets those that aren't for a prefix of its query
+func (s *Searcher) store(key searchCacheKey, search *cachedSearch) {
+	if search.positions == nil {
+		search.positions = []int32{} // nil would mean nothing is ruled out
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for cachedKey := range s.cache {
+		if !strings.HasPrefix(key.query, cachedKey.query) {
+			delete(s.cache, cachedKey)
+		}
+	}
+	s.cache[key] = search
+}
+
+// narrowable reports whether the matches of query are a subset of the matches of
+// every prefix of it, so a search for it only has to look at a prefix's matches.
+// Smart case keeps this true: a term only turns case sensitive by growing an uppercase
+// letter, and whatever it matches then its case insensitive prefix matched too.
+// Negations and alternatives can match more as they grow, "foo$" is no longer a
+// suffix term once extended, a backslash may turn into an escaped space, and
+// selectors compare whole words, so "cmd:gi" doesn't match what "cmd:git" does.
+func narrowable(query string) bool {
+	if strings.ContainsAny(query, `!|$\`) {
+		return false
+	}
+	for key := range fieldSelectors {
+		if strings.Contains(query, key+":") {
+			return false
+		}
+	}
+	return true
+}
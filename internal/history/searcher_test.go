@@ -0,0 +1,84 @@
+package history
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestSearcherMatchesIndex(t *testing.T) {
+	ix := NewIndex(benchmarkCommands(5000))
+	s := NewSearcher(ix)
+	ctx := context.Background()
+
+	// Type a query, delete part of it and type something else
+	queries := []string{"d", "de", "dep", "depl", "deploy", "deploy ", "deploy p", "deploy", "dep", "depo", "g", "gi", "git ", "gi",
+		"git !", "git !p", "git !pu", "git !pus", "git !push", "git", "git$", "git$ ", "git$ 1", "^go", "^go |", "^go | ^ls",
+		"cmd:g", "cmd:gi", "cmd:git", "cmd:git arg:--f", "cmd:git arg:--force", "sub:stat", "sub:status"}
+	for _, query := range queries {
+		exact, err := s.SearchExact(ctx, query, CaseSmart)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _ := ix.SearchExact(ctx, query, CaseSmart)
+		if !reflect.DeepEqual(exact, want) {
+			t.Errorf("SearchExact(%q) returned %d commands, want %d", query, len(exact), len(want))
+		}
+
+		fuzzy, err := s.SearchFuzzyWithPositions(ctx, query, CaseSmart)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantFuzzy, _ := ix.SearchFuzzyWithPositions(ctx, query, CaseSmart)
+		if !reflect.DeepEqual(fuzzy, wantFuzzy) {
+			t.Errorf("SearchFuzzyWithPositions(%q) returned %d results, want %d", query, len(fuzzy), len(wantFuzzy))
+		}
+	}
+}
+
+func TestSearcherCache(t *testing.T) {
+	s := NewSearcher(NewIndex([]Command{
+		{Index: 1, Text: "git commit"},
+		{Index: 2, Text: "git checkout"},
+		{Index: 3, Text: "go test"},
+	}))
+	ctx := context.Background()
+
+	for _, query := range []string{"g", "gi", "git", "git c", "git co"} {
+		s.SearchExact(ctx, query, CaseSmart)
+	}
+
+	// Backspacing is answered from the cache
+	cached, narrowed := s.lookup(searchCacheKey{caseMode: CaseSmart, query: "git c"})
+	if cached == nil || len(cached.exact) != 2 {
+		t.Fatalf("lookup(git c) = %v, want the cached 2 results", cached)
+	}
+
+	// Extending a query narrows the search to the longest cached prefix
+	_, narrowed = s.lookup(searchCacheKey{caseMode: CaseSmart, query: "git com"})
+	if want := []int32{0}; !reflect.DeepEqual(narrowed, want) {
+		t.Errorf("lookup(git com) narrowed to %v, want %v", narrowed, want)
+	}
+
+	// Queries that aren't prefixes of the latest one are forgotten
+	s.SearchExact(ctx, "go", CaseSmart)
+	if cached, _ := s.lookup(searchCacheKey{caseMode: CaseSmart, query: "git c"}); cached != nil {
+		t.Errorf("lookup(git c) after searching go = %v, want nil", cached)
+	}
+	if cached, _ := s.lookup(searchCacheKey{caseMode: CaseSmart, query: "g"}); cached == nil {
+		t.Error("lookup(g) after searching go = nil, want the cached results")
+	}
+}
+
+func BenchmarkSearcherTyping(b *testing.B) {
+	ix := NewIndex(benchmarkCommands(benchmarkHistorySize))
+	query := "deploy prod"
+	for b.Loop() {
+		// A fresh searcher per iteration so each keystroke narrows the previous one
+		s := NewSearcher(ix)
+		for i := 1; i <= len(query); i++ {
+			s.SearchFuzzyWithPositions(context.Background(), query[:i], CaseSmart)
+		}
+	}
+	reportBudget(b, len(query))
+}
@@ -0,0 +1,82 @@
+package history
+
+import (
+	"sort"
+	"time"
+)
+
+// SortMode determines how search results are ordered
+type SortMode string
+
+const (
+	// SortModeRelevance keeps the order produced by the search (history order or fuzzy score)
+	SortModeRelevance SortMode = "relevance"
+	// SortModeDuration puts the longest-running commands first
+	SortModeDuration SortMode = "duration"
+	// SortModeFrecency puts frequently and recently used commands first
+	SortModeFrecency SortMode = "frecency"
+)
+
+// sortModes lists the sort modes in the order they are cycled through
+var sortModes = []SortMode{SortModeRelevance, SortModeFrecency, SortModeDuration}
+
+// SortOptions carries what sort modes beyond relevance need to rank commands
+type SortOptions struct {
+	Usage    UsageStats      // Occurrence counts used by SortModeFrecency
+	Frecency FrecencyWeights // Weights used by SortModeFrecency
+	Now      time.Time       // Reference time for recency; zero means time.Now()
+}
+
+// ParseSortMode converts a config value into a SortMode, reporting whether it is known
+func ParseSortMode(value string) (SortMode, bool) {
+	for _, mode := range sortModes {
+		if string(mode) == value {
+			return mode, true
+		}
+	}
+	return SortModeRelevance, false
+}
+
+// Next returns the sort mode that follows s
+func (s SortMode) Next() SortMode {
+	for i, mode := range sortModes {
+		if mode == s {
+			return sortModes[(i+1)%len(sortModes)]
+		}
+	}
+	return SortModeRelevance
+}
+
+// SortCommands returns a copy of commands ordered by the given mode.
+// The sort is stable, so commands that compare equal keep their search order.
+func SortCommands(commands []Command, mode SortMode, opts SortOptions) []Command {
+	if mode == SortModeRelevance || len(commands) < 2 {
+		return commands
+	}
+
+	sorted := make([]Command, len(commands))
+	copy(sorted, commands)
+
+	switch mode {
+	case SortModeDuration:
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return sorted[i].Duration > sorted[j].Duration
+		})
+	case SortModeFrecency:
+		now := opts.Now
+		if now.IsZero() {
+			now = time.Now()
+		}
+		scores := make(map[string]float64, len(sorted))
+		for _, cmd := range sorted {
+			if _, ok := scores[cmd.Text]; !ok {
+				scores[cmd.Text] = FrecencyScore(cmd, opts.Usage[cmd.Text], now, opts.Frecency)
+			}
+		}
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return scores[sorted[i].Text] > scores[sorted[j].Text]
+		})
+	}
+
+	return sorted
+}
@@ -0,0 +1,90 @@
+package history
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSortCommandsByDuration(t *testing.T) {
+	cmds := []Command{
+		{Index: 1, Text: "ls", Duration: 0},
+		{Index: 2, Text: "make build", Duration: 95 * time.Second},
+		{Index: 3, Text: "go test ./...", Duration: 12 * time.Second},
+		{Index: 4, Text: "make build", Duration: 95 * time.Second},
+	}
+
+	got := SortCommands(cmds, SortModeDuration, SortOptions{})
+	wantIndexes := []int{2, 4, 3, 1}
+	for i, cmd := range got {
+		if cmd.Index != wantIndexes[i] {
+			t.Errorf("SortCommands(duration)[%d].Index = %d, want %d", i, cmd.Index, wantIndexes[i])
+		}
+	}
+
+	if cmds[0].Index != 1 {
+		t.Errorf("SortCommands modified its input")
+	}
+
+	relevance := SortCommands(cmds, SortModeRelevance, SortOptions{})
+	for i, cmd := range relevance {
+		if cmd.Index != cmds[i].Index {
+			t.Errorf("SortCommands(relevance)[%d].Index = %d, want %d", i, cmd.Index, cmds[i].Index)
+		}
+	}
+}
+
+func TestSortCommandsByFrecency(t *testing.T) {
+	now := time.Unix(10_000_000, 0)
+	day := 24 * time.Hour
+	cmds := []Command{
+		{Index: 1, Text: "old favourite", Timestamp: now.Add(-90 * day)},
+		{Index: 2, Text: "old favourite", Timestamp: now.Add(-89 * day)},
+		{Index: 3, Text: "old favourite", Timestamp: now.Add(-88 * day)},
+		{Index: 4, Text: "one-off", Timestamp: now.Add(-30 * day)},
+		{Index: 5, Text: "daily driver", Timestamp: now.Add(-2 * day)},
+		{Index: 6, Text: "daily driver", Timestamp: now.Add(-1 * day)},
+		{Index: 7, Text: "no timestamp"},
+	}
+	// Rank with the weights of the default config
+	opts := SortOptions{
+		Usage:    NewUsageStats(cmds),
+		Frecency: FrecencyWeights{Frequency: 1, Recency: 5, HalfLife: 168 * time.Hour},
+		Now:      now,
+	}
+
+	got := SortCommands(cmds, SortModeFrecency, opts)
+	wantTexts := []string{"daily driver", "daily driver", "old favourite", "old favourite", "old favourite", "one-off", "no timestamp"}
+	for i, cmd := range got {
+		if cmd.Text != wantTexts[i] {
+			t.Errorf("SortCommands(frecency)[%d] = %q, want %q", i, cmd.Text, wantTexts[i])
+		}
+	}
+}
+
+func TestFrecencyScore(t *testing.T) {
+	now := time.Unix(10_000_000, 0)
+	weights := FrecencyWeights{Frequency: 1, Recency: 4, HalfLife: time.Hour}
+
+	fresh := FrecencyScore(Command{Timestamp: now}, nil, now, weights)
+	if fresh != 5 { // log2(2) + 4
+		t.Errorf("fresh score = %v, want 5", fresh)
+	}
+	halfLife := FrecencyScore(Command{Timestamp: now.Add(-time.Hour)}, nil, now, weights)
+	if halfLife != 3 { // log2(2) + 4/2
+		t.Errorf("score after one half-life = %v, want 3", halfLife)
+	}
+	frequent := FrecencyScore(Command{}, &Usage{Count: 7}, now, weights)
+	if frequent != 3 { // log2(8), no timestamp
+		t.Errorf("untimestamped score = %v, want 3", frequent)
+	}
+}
+
+func TestSortModeNext(t *testing.T) {
+	mode := SortModeRelevance
+	for _, want := range []SortMode{SortModeFrecency, SortModeDuration, SortModeRelevance} {
+		mode = mode.Next()
+		if mode != want {
+			t.Errorf("Next() = %q, want %q", mode, want)
+		}
+	}
+}
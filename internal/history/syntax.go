@@ -0,0 +1,118 @@
+package history
+
+import "strings"
+
+// SyntaxKind is the part of shell syntax a span of a command line belongs to
+type SyntaxKind int
+
+const (
+	SyntaxPlain    SyntaxKind = iota // Arguments and anything else without a kind of its own
+	SyntaxProgram                    // Program a simple command runs
+	SyntaxFlag                       // Option like -v or --force
+	SyntaxString                     // Quoted string, quotes included
+	SyntaxVariable                   // Parameter expansion like $HOME or ${1}, command substitution, or the name of an assignment
+	SyntaxOperator                   // Control operator like | or && and redirection like > or 2>&1
+	SyntaxComment                    // Comment to the end of the line
+)
+
+// SyntaxSpan is a span of a command line of one SyntaxKind, in byte offsets
+type SyntaxSpan struct {
+	Kind       SyntaxKind
+	Start, End int
+}
+
+// redirectionOperators are the redirections a word may start with, longest first
+var redirectionOperators = []string{"&>>", "<<<", ">>", ">&", ">|", "<&", "<<", "<>", "&>", ">", "<"}
+
+// ShellSyntax splits a command line into spans for syntax highlighting, classifying the
+// tokens ParseCommandLine reads its commands from. Plain text between the spans is left
+// out, so the spans are sorted and don't overlap. Strings and variables inside a program
+// name or flag keep their own kind, like "$HOME" in --dir="$HOME".
+func ShellSyntax(text string) []SyntaxSpan {
+	kinds := make([]SyntaxKind, len(text))
+	mark := func(kind SyntaxKind, start, end int) {
+		for i := start; i < end; i++ {
+			kinds[i] = kind
+		}
+	}
+
+	commandStart := true // The next word may be the program of a new command
+	redirectTarget := false
+	for _, token := range scanCommandLine(text) {
+		switch token.kind {
+		case operatorToken:
+			mark(SyntaxOperator, token.start, token.end)
+			commandStart, redirectTarget = true, false
+		case commentToken:
+			mark(SyntaxComment, token.start, token.end)
+		case wordToken:
+			for _, part := range token.parts {
+				mark(part.kind, part.start, part.end)
+			}
+			word := text[token.start:token.end]
+			kind := SyntaxPlain
+			n, fd := redirectionLength(word)
+			switch {
+			case redirectTarget:
+				redirectTarget = false
+			case n > 0:
+				mark(SyntaxOperator, token.start, token.start+n)
+				redirectTarget = n == len(word) && !fd // Like "> out" rather than ">out" or "2>&1"
+			case commandStart && isAssignment(word):
+				name, _, _ := strings.Cut(word, "=")
+				mark(SyntaxVariable, token.start, token.start+len(name))
+			case commandStart:
+				kind = SyntaxProgram
+				commandStart = false
+			case strings.HasPrefix(word, "-"):
+				kind = SyntaxFlag
+			}
+			if kind != SyntaxPlain {
+				for j := token.start; j < token.end; j++ {
+					if kinds[j] == SyntaxPlain {
+						kinds[j] = kind
+					}
+				}
+			}
+		}
+	}
+
+	var spans []SyntaxSpan
+	for i := 0; i < len(kinds); i++ {
+		if kinds[i] == SyntaxPlain {
+			continue
+		}
+		start := i
+		for i+1 < len(kinds) && kinds[i+1] == kinds[start] {
+			i++
+		}
+		spans = append(spans, SyntaxSpan{Kind: kinds[start], Start: start, End: i + 1})
+	}
+	return spans
+}
+
+// redirectionLength returns the length of the redirection operator word starts with,
+// like 2 for "2>file" or 4 for "2>&1", or 0 if word isn't a redirection. It also
+// reports whether the operator names the file descriptor it duplicates, like 2>&1 does.
+func redirectionLength(word string) (int, bool) {
+	digits := 0
+	for digits < len(word) && '0' <= word[digits] && word[digits] <= '9' {
+		digits++
+	}
+	for _, op := range redirectionOperators {
+		if !strings.HasPrefix(word[digits:], op) {
+			continue
+		}
+		n := digits + len(op)
+		if !strings.HasSuffix(op, "&") {
+			return n, false
+		}
+		// Duplicating a file descriptor names it right after the operator, like 2>&1 or >&-
+		fd := n
+		for fd < len(word) && ('0' <= word[fd] && word[fd] <= '9' || word[fd] == '-') {
+			fd++
+		}
+		return fd, fd > n
+	}
+	return 0, false
+}
@@ -0,0 +1,46 @@
+package history
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestShellSyntax(t *testing.T) {
+	// Each span is written as its kind's letter followed by the text it covers
+	letters := map[SyntaxKind]string{
+		SyntaxProgram:  "p",
+		SyntaxFlag:     "f",
+		SyntaxString:   "s",
+		SyntaxVariable: "v",
+		SyntaxOperator: "o",
+		SyntaxComment:  "c",
+	}
+
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"git push --force origin", []string{"p:git", "f:--force"}},
+		{"cat log | grep -i err && wc -l", []string{"p:cat", "o:|", "p:grep", "f:-i", "o:&&", "p:wc", "f:-l"}},
+		{`echo 'a | b' "home $HOME" x`, []string{"p:echo", "s:'a | b'", `s:"home `, "v:$HOME", `s:"`}},
+		{"echo ${USER:-me} $(date +%s) `id` $1 $", []string{"p:echo", "v:${USER:-me}", "v:$(date +%s)", "v:`id`", "v:$1"}},
+		{"make 2>&1 >/dev/null |& tee log", []string{"p:make", "o:2>&1", "o:>", "o:|&", "p:tee"}},
+		{"sort < in > out", []string{"p:sort", "o:<", "o:>"}},
+		{"echo x >| out; ls", []string{"p:echo", "o:>|", "o:;", "p:ls"}},
+		{"FOO=1 go test; ls # a | b", []string{"v:FOO", "p:go", "o:;", "p:ls", "c:# a | b"}},
+		{`grep --file="$HOME/x" a#b`, []string{"p:grep", "f:--file=", `s:"`, "v:$HOME", `s:/x"`}},
+		{"echo $(a; b) | wc", []string{"p:echo", "v:$(a; b)", "o:|", "p:wc"}},
+		{"echo 'unterminated", []string{"p:echo", "s:'unterminated"}},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, span := range ShellSyntax(tt.text) {
+			got = append(got, letters[span.Kind]+":"+tt.text[span.Start:span.End])
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ShellSyntax(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
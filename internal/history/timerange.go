@@ -0,0 +1,189 @@
+package history
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeLayouts are the absolute times accepted by time range tokens, in the local time zone
+var timeLayouts = []string{
+	"2006-01-02",
+	"2006-01-02T15:04",
+	"2006-01-02T15:04:05",
+}
+
+// durationUnits are the units of relative times like "3d"
+var durationUnits = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+	"y": 365 * 24 * time.Hour,
+}
+
+// TimeRange limits commands to those run at or after After and before Before.
+// A zero bound doesn't limit that side, and the zero TimeRange matches every command.
+type TimeRange struct {
+	After  time.Time
+	Before time.Time
+}
+
+// IsZero reports whether r matches every command
+func (r TimeRange) IsZero() bool {
+	return r.After.IsZero() && r.Before.IsZero()
+}
+
+// Contains reports whether a command run at ts is in r. Commands without a timestamp
+// can't be placed in time, so they're only in the zero TimeRange.
+func (r TimeRange) Contains(ts time.Time) bool {
+	if r.IsZero() {
+		return true
+	}
+	if ts.IsZero() {
+		return false
+	}
+	return (r.After.IsZero() || !ts.Before(r.After)) && (r.Before.IsZero() || ts.Before(r.Before))
+}
+
+// Filter returns the commands in r, keeping their order
+func (r TimeRange) Filter(commands []Command) []Command {
+	if r.IsZero() {
+		return commands
+	}
+	var filtered []Command
+	for _, cmd := range commands {
+		if r.Contains(cmd.Timestamp) {
+			filtered = append(filtered, cmd)
+		}
+	}
+	return filtered
+}
+
+// ParseTimeRange takes the time range tokens out of a query and returns the range they
+// describe along with the rest of the query. Several tokens narrow the range together.
+//
+//	after:2025-06-01   run at or after a time
+//	before:3d          run before a time
+//	on:yesterday       run during a day
+//	within:2h          run within a duration of now
+//
+// Times are dates like 2025-06-01 or 2025-06-01T15:04 in the local time zone, "now",
+// "today", "yesterday", or durations before now like 90s, 30m, 2h, 3d, 1w or 1y.
+// A token without a value is ignored, so a half typed one doesn't filter anything yet.
+// The rest of the query keeps its spacing, so escaped spaces still work.
+func ParseTimeRange(input string, now time.Time) (TimeRange, string, error) {
+	var r TimeRange
+	var rest []string
+	tokens := strings.Split(input, " ")
+	for i, token := range tokens {
+		key, value, ok := strings.Cut(token, ":")
+		escaped := i > 0 && strings.HasSuffix(tokens[i-1], `\`) // Part of a term with an escaped space
+		if !ok || escaped || !isTimeRangeKey(key) {
+			rest = append(rest, token)
+			continue
+		}
+		if value == "" {
+			continue
+		}
+		if err := r.narrow(key, value, now); err != nil {
+			return TimeRange{}, "", err
+		}
+	}
+	return r, strings.Join(rest, " "), nil
+}
+
+// isTimeRangeKey reports whether key names a time range token
+func isTimeRangeKey(key string) bool {
+	switch key {
+	case "after", "before", "on", "within":
+		return true
+	}
+	return false
+}
+
+// narrow limits r by the token key:value
+func (r *TimeRange) narrow(key, value string, now time.Time) error {
+	var after, before time.Time
+	switch key {
+	case "within":
+		d, ok := parseDurationValue(value)
+		if !ok {
+			return fmt.Errorf("within:%s: want a duration like 2h or 3d", value)
+		}
+		after = now.Add(-d)
+	case "on":
+		t, err := parseTimeValue(key, value, now)
+		if err != nil {
+			return err
+		}
+		after = startOfDay(t)
+		before = after.AddDate(0, 0, 1)
+	case "after":
+		t, err := parseTimeValue(key, value, now)
+		if err != nil {
+			return err
+		}
+		after = t
+	case "before":
+		t, err := parseTimeValue(key, value, now)
+		if err != nil {
+			return err
+		}
+		before = t
+	}
+
+	if !after.IsZero() && after.After(r.After) {
+		r.After = after
+	}
+	if !before.IsZero() && (r.Before.IsZero() || before.Before(r.Before)) {
+		r.Before = before
+	}
+	return nil
+}
+
+// parseTimeValue parses the time of a key:value token relative to now
+func parseTimeValue(key, value string, now time.Time) (time.Time, error) {
+	switch value {
+	case "now":
+		return now, nil
+	case "today":
+		return startOfDay(now), nil
+	case "yesterday":
+		return startOfDay(now).AddDate(0, 0, -1), nil
+	}
+	if d, ok := parseDurationValue(value); ok {
+		return now.Add(-d), nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, value, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%s:%s: want a date like 2025-06-01, a duration like 3d, today or yesterday", key, value)
+}
+
+// parseDurationValue parses a count followed by one of the durationUnits
+func parseDurationValue(value string) (time.Duration, bool) {
+	n := len(value) - 1
+	if n < 1 {
+		return 0, false
+	}
+	unit, ok := durationUnits[value[n:]]
+	if !ok {
+		return 0, false
+	}
+	count, err := strconv.Atoi(value[:n])
+	if err != nil || count < 0 {
+		return 0, false
+	}
+	return time.Duration(count) * unit, true
+}
+
+// startOfDay returns midnight at the start of t's day in t's location
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,89 @@
+package history
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseTimeRange(t *testing.T) {
+	now := time.Date(2025, 6, 15, 14, 30, 0, 0, time.UTC)
+	day := func(d int) time.Time { return time.Date(2025, 6, d, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		input string
+		want  TimeRange
+		rest  string
+	}{
+		{"git push", TimeRange{}, "git push"},
+		{"after:2025-06-01 git", TimeRange{After: day(1)}, "git"},
+		{"git before:3d", TimeRange{Before: now.Add(-72 * time.Hour)}, "git"},
+		{"on:yesterday", TimeRange{After: day(14), Before: day(15)}, ""},
+		{"on:2025-06-03", TimeRange{After: day(3), Before: day(4)}, ""},
+		{"within:2h make", TimeRange{After: now.Add(-2 * time.Hour)}, "make"},
+		{"after:today", TimeRange{After: day(15)}, ""},
+		{"after:2025-06-01T09:15", TimeRange{After: time.Date(2025, 6, 1, 9, 15, 0, 0, time.UTC)}, ""},
+		{"after:2025-06-01 after:1w before:now", TimeRange{After: day(8).Add(14*time.Hour + 30*time.Minute), Before: now}, ""},
+		{"after: git", TimeRange{}, "git"},
+		{`echo\ on:air`, TimeRange{}, `echo\ on:air`},
+		{"key:value", TimeRange{}, "key:value"},
+	}
+
+	for _, tt := range tests {
+		got, rest, err := ParseTimeRange(tt.input, now)
+		if err != nil {
+			t.Errorf("ParseTimeRange(%q) error = %v", tt.input, err)
+			continue
+		}
+		if !got.After.Equal(tt.want.After) || !got.Before.Equal(tt.want.Before) {
+			t.Errorf("ParseTimeRange(%q) = %+v, want %+v", tt.input, got, tt.want)
+		}
+		if rest != tt.rest {
+			t.Errorf("ParseTimeRange(%q) rest = %q, want %q", tt.input, rest, tt.rest)
+		}
+	}
+}
+
+func TestParseTimeRangeInvalid(t *testing.T) {
+	now := time.Date(2025, 6, 15, 14, 30, 0, 0, time.UTC)
+	for _, input := range []string{"after:soon", "before:2025-13-01", "within:yesterday", "on:3x", "within:-2h"} {
+		if _, _, err := ParseTimeRange(input, now); err == nil {
+			t.Errorf("ParseTimeRange(%q) succeeded, want an error", input)
+		}
+	}
+}
+
+func TestTimeRangeFilter(t *testing.T) {
+	cmds := []Command{
+		{Index: 1, Text: "old", Timestamp: time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)},
+		{Index: 2, Text: "unknown"},
+		{Index: 3, Text: "boundary", Timestamp: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{Index: 4, Text: "recent", Timestamp: time.Date(2025, 6, 14, 9, 0, 0, 0, time.UTC)},
+	}
+
+	tests := []struct {
+		name string
+		r    TimeRange
+		want []int
+	}{
+		{"zero range keeps commands without a timestamp", TimeRange{}, []int{1, 2, 3, 4}},
+		{"after is inclusive", TimeRange{After: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)}, []int{3, 4}},
+		{"before is exclusive", TimeRange{Before: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)}, []int{1}},
+		{"both bounds", TimeRange{
+			After:  time.Date(2025, 5, 2, 0, 0, 0, 0, time.UTC),
+			Before: time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC),
+		}, []int{3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int
+			for _, cmd := range tt.r.Filter(cmds) {
+				got = append(got, cmd.Index)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Filter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package history
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// watchPollInterval is how often files are checked when inotify is unavailable
+const watchPollInterval = 500 * time.Millisecond
+
+// Watcher reports commands appended to history files after they were loaded,
+// e.g. by another shell running with INC_APPEND_HISTORY.
+type Watcher struct {
+	files     []*watchedFile
+	opts      LoadOptions
+	nextIndex int // Index given to the next appended command
+}
+
+// watchedFile tracks how far one history file has been read
+type watchedFile struct {
+	path      string
+	shell     Shell
+	state     fileState
+	delivered int       // Commands of this file already handed out, by per-file index
+	latest    time.Time // Latest timestamp of the commands handed out, zero if none had one
+}
+
+// LoadAndWatchHistoryFiles loads history files like LoadAndParseHistoryFiles and
+// returns a Watcher that continues from where loading stopped.
+func LoadAndWatchHistoryFiles(paths []string, shell Shell, opts LoadOptions) ([]Command, *Watcher, error) {
+	watcher := &Watcher{opts: opts}
+	lists := make([][]Command, 0, len(paths))
+	for _, path := range paths {
+		expanded, err := ExpandPath(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		if absPath, err := filepath.Abs(expanded); err == nil {
+			expanded = absPath
+		}
+
+		fileShell := ShellForPath(expanded, shell)
+		cmds, state, err := loadHistoryFile(expanded, fileShell, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		lists = append(lists, cmds)
+		watcher.files = append(watcher.files, &watchedFile{
+			path:      expanded,
+			shell:     fileShell,
+			state:     state,
+			delivered: len(cmds),
+			latest:    latestTimestamp(cmds),
+		})
+	}
+
+	merged := MergeCommands(lists...)
+	watcher.nextIndex = len(merged) + 1
+	return merged, watcher, nil
+}
+
+// Run watches the files until ctx is cancelled, calling onAppend with every
+// batch of newly appended commands. It uses inotify where available and falls
+// back to polling otherwise.
+func (w *Watcher) Run(ctx context.Context, onAppend func([]Command)) {
+	names := make(map[string]bool, len(w.files))
+	for _, f := range w.files {
+		names[f.path] = true
+	}
+
+	events, err := watchFiles(ctx, names)
+	var poll <-chan time.Time
+	if err != nil {
+		ticker := time.NewTicker(watchPollInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-events:
+			if !ok {
+				// The notifier failed; keep going by polling
+				events = nil
+				ticker := time.NewTicker(watchPollInterval)
+				defer ticker.Stop()
+				poll = ticker.C
+				continue
+			}
+		case <-poll:
+		}
+
+		if cmds := w.poll(); len(cmds) > 0 {
+			onAppend(cmds)
+		}
+	}
+}
+
+// poll reads whatever was appended to the files since the last call
+func (w *Watcher) poll() []Command {
+	lists := make([][]Command, 0, len(w.files))
+	for _, f := range w.files {
+		if cmds := w.readAppended(f); len(cmds) > 0 {
+			lists = append(lists, cmds)
+		}
+	}
+	if len(lists) == 0 {
+		return nil
+	}
+
+	// Continue numbering after everything delivered so far
+	appended := MergeCommands(lists...)
+	for i := range appended {
+		appended[i].Index = w.nextIndex
+		w.nextIndex++
+	}
+	return appended
+}
+
+// readAppended returns the commands added to f since it was last read.
+// A file that was rewritten (new inode or smaller size), like when the shell trims
+// it to its history size, is re-read whole. Its commands can no longer be matched
+// against what was delivered by position, so only those newer than every command
+// delivered so far are returned; untimed commands and those from the same second
+// as the latest delivered one are dropped.
+func (w *Watcher) readAppended(f *watchedFile) []Command {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		// The shell may be in the middle of replacing the file
+		return nil
+	}
+
+	if fileInode(info) != f.state.Inode || info.Size() < f.state.Size {
+		cmds, state, err := loadHistoryFile(f.path, f.shell, w.opts)
+		if err != nil {
+			return nil
+		}
+		f.state, f.delivered = state, len(cmds)
+		if f.latest.IsZero() {
+			// Nothing tells the new commands apart from the old ones
+			f.latest = latestTimestamp(cmds)
+			return nil
+		}
+		var newer []Command
+		for _, cmd := range cmds {
+			if cmd.Timestamp.After(f.latest) {
+				newer = append(newer, cmd)
+			}
+		}
+		if latest := latestTimestamp(newer); latest.After(f.latest) {
+			f.latest = latest
+		}
+		return newer
+	}
+	if info.Size() == f.state.Size {
+		return nil
+	}
+
+	file, err := os.Open(f.path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	// Parsing restarts at the file's last command, which was already delivered
+	var cmds []Command
+	state, err := parseFileFrom(file, info, f.shell, w.opts, f.state.Resume, func(cmd Command) {
+		if cmd.Index > f.delivered {
+			cmds = append(cmds, cmd)
+		}
+	})
+	if err != nil {
+		return nil
+	}
+	f.state = state
+	f.delivered += len(cmds)
+	if latest := latestTimestamp(cmds); latest.After(f.latest) {
+		f.latest = latest
+	}
+	return cmds
+}
+
+// latestTimestamp returns the latest timestamp of cmds, or zero if none has one
+func latestTimestamp(cmds []Command) time.Time {
+	var latest time.Time
+	for _, cmd := range cmds {
+		if cmd.Timestamp.After(latest) {
+			latest = cmd.Timestamp
+		}
+	}
+	return latest
+}
@@ -0,0 +1,102 @@
+//go:build linux
+
+package history
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// inotifyMask covers in-place appends as well as files replaced by rename
+const inotifyMask = unix.IN_MODIFY | unix.IN_CLOSE_WRITE | unix.IN_MOVED_TO | unix.IN_CREATE
+
+// watchFiles reports changes to the given files through inotify.
+// Parent directories are watched so files replaced via rename keep being followed.
+// A file that's a symlink, like a history file kept in a dotfiles repository, is
+// watched in both the link's directory and its target's, since writes to the target
+// don't raise events for the link. The returned channel is closed if the watch fails
+// after starting.
+func watchFiles(ctx context.Context, paths map[string]bool) (<-chan struct{}, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+
+	watched := make(map[string]bool, len(paths))
+	for path := range paths {
+		watched[path] = true
+		if target, err := filepath.EvalSymlinks(path); err == nil {
+			watched[target] = true
+		}
+	}
+	paths = watched
+
+	watchedDirs := make(map[int32]string)
+	for path := range paths {
+		dir := filepath.Dir(path)
+		wd, err := unix.InotifyAddWatch(fd, dir, inotifyMask)
+		if err != nil {
+			unix.Close(fd)
+			return nil, err
+		}
+		watchedDirs[int32(wd)] = dir
+	}
+
+	events := make(chan struct{}, 1)
+	go func() {
+		defer close(events)
+		defer unix.Close(fd)
+
+		buf := make([]byte, 64*1024)
+		pollFds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+		for ctx.Err() == nil {
+			// Wake up regularly to notice cancellation
+			n, err := unix.Poll(pollFds, 250)
+			if err == unix.EINTR || n == 0 {
+				continue
+			}
+			if err != nil {
+				return
+			}
+
+			changed := false
+			for {
+				read, err := unix.Read(fd, buf)
+				if err != nil || read <= 0 {
+					break
+				}
+				changed = changed || touchesWatchedFile(buf[:read], watchedDirs, paths)
+			}
+			if changed {
+				select {
+				case events <- struct{}{}:
+				default:
+					// A notification is already pending
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
+// touchesWatchedFile reports whether a batch of inotify events names one of the watched files
+func touchesWatchedFile(buf []byte, dirs map[int32]string, paths map[string]bool) bool {
+	for offset := 0; offset+unix.SizeofInotifyEvent <= len(buf); {
+		event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+		nameStart := offset + unix.SizeofInotifyEvent
+		nameEnd := nameStart + int(event.Len)
+		if nameEnd > len(buf) {
+			return false
+		}
+		name := string(bytes.TrimRight(buf[nameStart:nameEnd], "\x00"))
+		if paths[filepath.Join(dirs[event.Wd], name)] {
+			return true
+		}
+		offset = nameEnd
+	}
+	return false
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package history
+
+import (
+	"context"
+	"errors"
+)
+
+// watchFiles is only implemented with inotify; other platforms poll
+func watchFiles(ctx context.Context, paths map[string]bool) (<-chan struct{}, error) {
+	return nil, errors.New("file notifications are not supported on this platform")
+}
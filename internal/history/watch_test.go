@@ -0,0 +1,132 @@
+package history
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcherPoll(t *testing.T) {
+	dir := t.TempDir()
+	zshFile := filepath.Join(dir, "zsh_history")
+	bashFile := filepath.Join(dir, "bash_history")
+	writeFile(t, zshFile, ": 1700000000:0;ls\n")
+	writeFile(t, bashFile, "#1700000100\npwd\n")
+
+	cmds, watcher, err := LoadAndWatchHistoryFiles([]string{zshFile, bashFile}, ShellZsh, DefaultLoadOptions())
+	if err != nil {
+		t.Fatalf("LoadAndWatchHistoryFiles error: %v", err)
+	}
+	if len(cmds) != 2 {
+		t.Fatalf("loaded %d commands, want 2", len(cmds))
+	}
+
+	if got := watcher.poll(); len(got) != 0 {
+		t.Errorf("poll() without changes = %v, want nothing", got)
+	}
+
+	appendFile(t, zshFile, ": 1700000300:0;git status\n")
+	appendFile(t, bashFile, "#1700000200\nmake\n")
+	got := watcher.poll()
+	wantTexts := []string{"make", "git status"}
+	if len(got) != len(wantTexts) {
+		t.Fatalf("poll() = %v, want %v", got, wantTexts)
+	}
+	for i, cmd := range got {
+		if cmd.Text != wantTexts[i] || cmd.Index != 3+i {
+			t.Errorf("poll()[%d] = {%d %q}, want {%d %q}", i, cmd.Index, cmd.Text, 3+i, wantTexts[i])
+		}
+	}
+
+	// A rewritten file reports only the commands newer than those delivered before
+	replaceFile(t, zshFile, ": 1700000300:0;git status\n: 1700000400:0;x\n")
+	if got := watcher.poll(); len(got) != 1 || got[0].Text != "x" || got[0].Index != 5 {
+		t.Errorf("poll() after rewrite = %v, want [{5 x}]", got)
+	}
+	replaceFile(t, zshFile, ": 1700000400:0;x\n")
+	if got := watcher.poll(); len(got) != 0 {
+		t.Errorf("poll() after trimming = %v, want nothing", got)
+	}
+	appendFile(t, zshFile, ": 1700000500:0;after rewrite\n")
+	if got := watcher.poll(); len(got) != 1 || got[0].Text != "after rewrite" || got[0].Index != 6 {
+		t.Errorf("poll() after rewrite and append = %v, want [{6 after rewrite}]", got)
+	}
+
+	// Without timestamps there's no telling which commands of a rewritten file are new
+	replaceFile(t, bashFile, "pwd\nmake\ntop\n")
+	if got := watcher.poll(); len(got) != 0 {
+		t.Errorf("poll() after rewriting an untimed file = %v, want nothing", got)
+	}
+}
+
+func TestWatcherRunSymlink(t *testing.T) {
+	// Like a history file kept in a dotfiles repository and linked into $HOME
+	target := filepath.Join(t.TempDir(), "zsh_history")
+	link := filepath.Join(t.TempDir(), ".zsh_history")
+	writeFile(t, target, ": 1700000000:0;ls\n")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+
+	_, watcher, err := LoadAndWatchHistoryFiles([]string{link}, ShellZsh, DefaultLoadOptions())
+	if err != nil {
+		t.Fatalf("LoadAndWatchHistoryFiles error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	appended := make(chan []Command, 1)
+	go watcher.Run(ctx, func(cmds []Command) { appended <- cmds })
+
+	time.Sleep(50 * time.Millisecond)
+	appendFile(t, target, ": 1700000100:0;echo linked\n")
+
+	select {
+	case cmds := <-appended:
+		if len(cmds) != 1 || cmds[0].Text != "echo linked" {
+			t.Errorf("appended = %v, want [{2 echo linked}]", cmds)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for a command appended to the symlink's target")
+	}
+}
+
+// replaceFile replaces path with a new file of content, like a shell rewriting its history
+func replaceFile(t *testing.T, path, content string) {
+	t.Helper()
+	tmp := path + ".new"
+	writeFile(t, tmp, content)
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatcherRun(t *testing.T) {
+	historyFile := filepath.Join(t.TempDir(), "zsh_history")
+	writeFile(t, historyFile, ": 1700000000:0;ls\n")
+
+	_, watcher, err := LoadAndWatchHistoryFiles([]string{historyFile}, ShellZsh, DefaultLoadOptions())
+	if err != nil {
+		t.Fatalf("LoadAndWatchHistoryFiles error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	appended := make(chan []Command, 1)
+	go watcher.Run(ctx, func(cmds []Command) { appended <- cmds })
+
+	// Give the watcher a moment to register before appending
+	time.Sleep(50 * time.Millisecond)
+	appendFile(t, historyFile, ": 1700000100:0;echo live\n")
+
+	select {
+	case cmds := <-appended:
+		if len(cmds) != 1 || cmds[0].Text != "echo live" || cmds[0].Index != 2 {
+			t.Errorf("appended = %v, want [{2 echo live}]", cmds)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for appended command")
+	}
+}
@@ -3,125 +3,130 @@ package components
 import (
 	"strings"
 
+	"sheek/internal/history"
 	"sheek/internal/tui/styles"
+
+	"github.com/charmbracelet/lipgloss"
 )
 
-// HighlightMatches highlights matching text in the command text based on search input.
+// HighlightMatches renders the command text with shell syntax colors and highlights
+// the text matching the search input on top of them.
+// The input is parsed as an extended query and the spans of every positive term are marked.
+// Terms that ignore case under caseMode compare under Unicode case folding, so "école" highlights "ÉCOLE".
+// In SearchModeRegex the input is a regular expression instead and every match is marked;
+// a pattern that doesn't compile highlights nothing.
 // isSelected indicates if the item is currently selected, to use appropriate highlight style.
-func HighlightMatches(text, searchInput string, isSelected bool) string {
+func HighlightMatches(text, searchInput string, mode SearchMode, caseMode history.CaseMode, isSelected bool) string {
 	if strings.TrimSpace(searchInput) == "" {
-		return text
-	}
-
-	searchLower := strings.ToLower(searchInput)
-	textLower := strings.ToLower(text)
-
-	// Find all match positions (case-insensitive)
-	var matches []int
-	start := 0
-	for {
-		idx := strings.Index(textLower[start:], searchLower)
-		if idx == -1 {
-			break
-		}
-		actualIdx := start + idx
-		matches = append(matches, actualIdx)
-		start = actualIdx + len(searchLower)
-	}
-
-	if len(matches) == 0 {
-		return text
-	}
-
-	// Choose appropriate highlight style based on selection state
-	style := styles.HighlightStyle
-	if isSelected {
-		style = styles.HighlightSelectedStyle
+		return highlightRunes(text, nil, isSelected)
 	}
-
-	// Build highlighted text by inserting highlight styles at match positions
-	var result strings.Builder
-	lastPos := 0
-
-	for _, matchStart := range matches {
-		matchEnd := matchStart + len(searchLower)
-
-		// Add text before match
-		if matchStart > lastPos {
-			result.WriteString(text[lastPos:matchStart])
-		}
-
-		// Extract the actual matched substring (preserving original case)
-		matchedText := text[matchStart:matchEnd]
-
-		// Highlight the matched text with appropriate style
-		// For selected items, don't set background to preserve selected background
-		if isSelected {
-			// Use a style that only modifies foreground and underline, not background
-			highlighted := styles.HighlightSelectedStyle.Copy().
-				UnsetBackground().
-				Render(matchedText)
-			result.WriteString(highlighted)
-		} else {
-			highlighted := style.Render(matchedText)
-			result.WriteString(highlighted)
+	if mode == SearchModeRegex {
+		re, err := history.CompileRegex(searchInput, caseMode)
+		if err != nil {
+			return highlightRunes(text, nil, isSelected)
 		}
-
-		lastPos = matchEnd
+		return highlightRunes(text, history.RegexMatchPositions(text, re), isSelected)
 	}
-
-	// Add remaining text after last match
-	if lastPos < len(text) {
-		result.WriteString(text[lastPos:])
-	}
-
-	return result.String()
+	query := history.ParseQuery(searchInput, history.MatchExact, caseMode)
+	return highlightRunes(text, query.MatchPositions(text), isSelected)
 }
 
-// HighlightFuzzyMatches highlights individual character positions that matched in fuzzy search.
-// matchPositions is a slice of character indices that should be highlighted.
+// HighlightFuzzyMatches renders the command text with shell syntax colors and highlights
+// the individual character positions that matched in fuzzy search on top of them.
+// matchPositions is a slice of rune indices that should be highlighted.
 // isSelected indicates if the item is currently selected, to use appropriate highlight style.
 func HighlightFuzzyMatches(text string, matchPositions []int, isSelected bool) string {
-	if len(matchPositions) == 0 {
-		return text
+	return highlightRunes(text, matchPositions, isSelected)
+}
+
+// syntaxStyles maps each kind of shell syntax to its style
+func syntaxStyles() map[history.SyntaxKind]lipgloss.Style {
+	return map[history.SyntaxKind]lipgloss.Style{
+		history.SyntaxPlain:    styles.SyntaxPlainStyle,
+		history.SyntaxProgram:  styles.SyntaxProgramStyle,
+		history.SyntaxFlag:     styles.SyntaxFlagStyle,
+		history.SyntaxString:   styles.SyntaxStringStyle,
+		history.SyntaxVariable: styles.SyntaxVariableStyle,
+		history.SyntaxOperator: styles.SyntaxOperatorStyle,
+		history.SyntaxComment:  styles.SyntaxCommentStyle,
 	}
+}
+
+// runeStyle is how one rune of the command text is rendered
+type runeStyle struct {
+	kind    history.SyntaxKind
+	matched bool
+}
 
+// highlightRunes renders text in the style of its shell syntax and the runes at the
+// given rune positions with the highlight style layered over it, so a match keeps the
+// bold or italics of its syntax but takes the highlight color.
+// Consecutive runes of the same style are rendered together to keep the escape sequences short.
+func highlightRunes(text string, positions []int, isSelected bool) string {
 	// Convert match positions to a set for O(1) lookup
-	matchSet := make(map[int]bool, len(matchPositions))
-	for _, pos := range matchPositions {
-		if pos >= 0 && pos < len(text) {
+	matchSet := make(map[int]bool, len(positions))
+	for _, pos := range positions {
+		if pos >= 0 {
 			matchSet[pos] = true
 		}
 	}
 
-	// Choose appropriate highlight style based on selection state
-	style := styles.HighlightStyle
+	// Choose appropriate highlight style based on selection state.
+	// Every run sets the selected item's background and weight itself, since the
+	// reset at the end of a run would clear those of the item around it.
+	highlight := styles.HighlightStyle
 	if isSelected {
-		style = styles.HighlightSelectedStyle
+		highlight = styles.HighlightSelectedStyle
+	}
+	kindStyles := syntaxStyles()
+	render := func(rs runeStyle, s string) string {
+		style := kindStyles[rs.kind]
+		if rs.matched {
+			style = highlight.Inherit(style)
+		}
+		if isSelected {
+			style = style.
+				Background(styles.ListItemSelectedStyle.GetBackground()).
+				Bold(styles.ListItemSelectedStyle.GetBold())
+		}
+		return style.Render(s)
 	}
 
-	// Build highlighted text character by character
 	var result strings.Builder
-	for i, char := range text {
-		if matchSet[i] {
-			// Highlight this character
-			charStr := string(char)
-			if isSelected {
-				// Use a style that only modifies foreground and underline, not background
-				highlighted := styles.HighlightSelectedStyle.Copy().
-					UnsetBackground().
-					Render(charStr)
-				result.WriteString(highlighted)
-			} else {
-				highlighted := style.Render(charStr)
-				result.WriteString(highlighted)
-			}
-		} else {
-			// Regular character, no highlighting
+	var run strings.Builder
+	var current runeStyle
+	flush := func() {
+		if run.Len() > 0 {
+			result.WriteString(render(current, run.String()))
+			run.Reset()
+		}
+	}
+
+	spans := history.ShellSyntax(text)
+	runeIndex := 0
+	for offset, char := range text {
+		for len(spans) > 0 && spans[0].End <= offset {
+			spans = spans[1:]
+		}
+		rs := runeStyle{kind: history.SyntaxPlain, matched: matchSet[runeIndex]}
+		if len(spans) > 0 && spans[0].Start <= offset {
+			rs.kind = spans[0].Kind
+		}
+		if char == '\n' {
+			// Style each line on its own so multi-line runs aren't padded to a common width
+			flush()
 			result.WriteRune(char)
+			runeIndex++
+			continue
 		}
+		if rs != current {
+			flush()
+			current = rs
+		}
+		run.WriteRune(char)
+		runeIndex++
 	}
+	flush()
 
 	return result.String()
 }
-
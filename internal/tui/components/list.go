@@ -19,10 +19,14 @@ const (
 	SearchModeExact SearchMode = "Exact"
 	// SearchModeFuzzy performs fuzzy matching
 	SearchModeFuzzy SearchMode = "Fuzzy"
+	// SearchModeApprox tolerates a few typos per query word
+	SearchModeApprox SearchMode = "Approx"
+	// SearchModeRegex matches a regular expression
+	SearchModeRegex SearchMode = "Regex"
 )
 
 // RenderListComponent renders a sliding window of command list items with scrollbar
-func RenderListComponent(commands []history.Command, fuzzyPositions map[int][]int, selectedIndex, terminalWidth, terminalHeight int, searchInput string, searchMode SearchMode, maxVisibleItems, listContainerHeight, horizontalMargin int, showTimestamp bool) string {
+func RenderListComponent(commands []history.Command, fuzzyPositions map[int][]int, selectedIndex, terminalWidth, terminalHeight int, searchInput string, searchMode SearchMode, caseMode history.CaseMode, maxVisibleItems, listContainerHeight, horizontalMargin int, showTimestamp, showDuration bool, usage history.UsageStats) string {
 	if len(commands) == 0 {
 		emptyMessage := "No commands found"
 		return styles.ListContainerStyle.
@@ -43,7 +47,7 @@ func RenderListComponent(commands []history.Command, fuzzyPositions map[int][]in
 	itemWidth := calculateItemWidth(containerWidth, scrollbar != "")
 
 	// Render items with correct width for selected item highlighting
-	items := renderCommandItems(commands, fuzzyPositions, startIndex, endIndex, selectedIndex, searchInput, searchMode, itemWidth, maxVisibleItems, showTimestamp)
+	items := renderCommandItems(commands, fuzzyPositions, startIndex, endIndex, selectedIndex, searchInput, searchMode, caseMode, itemWidth, maxVisibleItems, showTimestamp, showDuration, usage)
 	listContent := strings.Join(items, "\n")
 
 	// If no scrollbar needed, return just the list
@@ -83,8 +87,9 @@ func calculateItemWidth(containerWidth int, hasScrollbar bool) int {
 	return containerWidth - 1 - 1 - 2 // -2 for padding
 }
 
-// renderCommandItems creates styled items for the visible range with highlighting
-func renderCommandItems(commands []history.Command, fuzzyPositions map[int][]int, start, end, selectedIndex int, searchInput string, searchMode SearchMode, itemWidth, maxVisibleItems int, showTimestamp bool) []string {
+// renderCommandItems creates styled items for the visible range with highlighting.
+// When usage is non-nil, commands run more than once get an occurrence count badge.
+func renderCommandItems(commands []history.Command, fuzzyPositions map[int][]int, start, end, selectedIndex int, searchInput string, searchMode SearchMode, caseMode history.CaseMode, itemWidth, maxVisibleItems int, showTimestamp, showDuration bool, usage history.UsageStats) []string {
 	items := make([]string, 0, maxVisibleItems)
 
 	for i := start; i < end && i < len(commands); i++ {
@@ -92,29 +97,36 @@ func renderCommandItems(commands []history.Command, fuzzyPositions map[int][]int
 		isSelected := i == selectedIndex
 
 		itemNumber := styles.ItemNumberStyle.Render(fmt.Sprintf("%d", cmd.Index))
-		var timestampContent string
+		columns := []string{itemNumber}
 		if showTimestamp {
-			timestampContent = styles.TimestampStyle.Render(formatTimestamp(cmd.Timestamp))
+			columns = append(columns, styles.TimestampStyle.Render(formatTimestamp(cmd.Timestamp)))
+		}
+		if showDuration {
+			columns = append(columns, styles.DurationStyle.Render(formatDuration(cmd.Duration)))
 		}
 
 		// Highlight matching text based on search mode
 		var highlightedText string
-		if searchMode == SearchModeFuzzy {
-			// Use fuzzy highlighting with match positions
+		if searchMode == SearchModeFuzzy || searchMode == SearchModeApprox {
+			// Highlight the positions the search matched
 			matchPositions := fuzzyPositions[cmd.Index]
 			highlightedText = HighlightFuzzyMatches(cmd.Text, matchPositions, isSelected)
 		} else {
-			// Use exact substring highlighting
-			highlightedText = HighlightMatches(cmd.Text, searchInput, isSelected)
+			// Highlight the query's substrings or regex matches
+			highlightedText = HighlightMatches(cmd.Text, searchInput, searchMode, caseMode, isSelected)
 		}
 		commandText := styles.CommandTextStyle.Render(highlightedText)
 
-		var itemContent string
-		if showTimestamp {
-			itemContent = lipgloss.JoinHorizontal(lipgloss.Top, itemNumber, timestampContent, commandText)
-		} else {
-			itemContent = lipgloss.JoinHorizontal(lipgloss.Top, itemNumber, commandText)
+		if usage != nil {
+			// Keep the column even for single commands so the text stays aligned
+			var count string
+			if u, ok := usage[cmd.Text]; ok && u.Count > 1 {
+				count = fmt.Sprintf("×%d", u.Count)
+			}
+			columns = append(columns, styles.CountBadgeStyle.Render(count))
 		}
+		columns = append(columns, commandText)
+		itemContent := lipgloss.JoinHorizontal(lipgloss.Top, columns...)
 
 		// Apply selected or normal style with full width to ensure background covers entire line
 		var styledItem string
@@ -189,3 +201,20 @@ func formatTimestamp(ts time.Time) string {
 		return ts.Format("2006-01-02")
 	}
 }
+
+// formatDuration renders a command's elapsed runtime compactly.
+// Zero durations (instant commands or shells that don't record it) render empty.
+func formatDuration(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm%02ds", int(d.Minutes()), int(d.Seconds())%60)
+	default:
+		return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+}
@@ -0,0 +1,141 @@
+package components
+
+import (
+	"fmt"
+	"strings"
+
+	"sheek/internal/history"
+	"sheek/internal/tui/styles"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// PreviewPosition is where the preview pane is placed next to the list
+type PreviewPosition string
+
+const (
+	// PreviewRight places the preview pane to the right of the list
+	PreviewRight PreviewPosition = "right"
+	// PreviewBottom places the preview pane below the list
+	PreviewBottom PreviewPosition = "bottom"
+)
+
+// RenderPreviewComponent renders the full text of the selected command, pretty-printed and
+// wrapped, below its index, absolute time and duration. The histories sheek reads don't
+// record exit statuses, so there are none to show. cmd is nil when nothing is selected.
+// width is the terminal width given to the pane and height the height of its content,
+// like the list's container height; text that doesn't fit ends with an ellipsis.
+func RenderPreviewComponent(cmd *history.Command, width, height, horizontalMargin int) string {
+	containerWidth := width - (horizontalMargin * 2) - 2
+	style := styles.PreviewContainerStyle.Width(containerWidth).Height(height)
+	if cmd == nil {
+		return style.Render(styles.EmptyStateStyle.Render("No command selected"))
+	}
+
+	textWidth := PreviewContentWidth(width, horizontalMargin)
+
+	lines := []string{previewDetail("Index", fmt.Sprintf("%d", cmd.Index))}
+	ranAt := "unknown"
+	if !cmd.Timestamp.IsZero() {
+		ranAt = cmd.Timestamp.Format("Mon 2006-01-02 15:04:05")
+	}
+	lines = append(lines, previewDetail("Time", ranAt))
+	if cmd.Duration > 0 {
+		lines = append(lines, previewDetail("Duration", formatDuration(cmd.Duration)))
+	}
+	lines = append(lines, "")
+
+	text := highlightRunes(prettyCommand(cmd.Text, textWidth), nil, false)
+	text = lipgloss.NewStyle().Width(textWidth).Render(text)
+	lines = append(lines, strings.Split(text, "\n")...)
+
+	if len(lines) > height {
+		lines = append(lines[:max(height-1, 0)], styles.PreviewLabelStyle.Render("…"))
+	}
+	return style.Render(strings.Join(lines, "\n"))
+}
+
+// RenderPreviewOutputComponent renders the output of a preview command from line scroll on,
+// keeping its ANSI colors, below a header with status and the lines shown out of all of them.
+// Lines wider than the pane are cut off rather than wrapped, so scrolling moves by whole lines.
+// width and height are like those of RenderPreviewComponent.
+func RenderPreviewOutputComponent(lines []string, scroll int, status string, width, height, horizontalMargin int) string {
+	containerWidth := width - (horizontalMargin * 2) - 2
+	textWidth := PreviewContentWidth(width, horizontalMargin)
+
+	visible := PreviewOutputHeight(height)
+	scroll = max(min(scroll, len(lines)-visible), 0)
+	end := min(scroll+visible, len(lines))
+
+	header := status
+	if len(lines) > visible {
+		position := fmt.Sprintf("%d–%d/%d", scroll+1, end, len(lines))
+		if header != "" {
+			header += " · "
+		}
+		header += position
+	}
+
+	content := []string{styles.PreviewHeaderStyle.Render(ansi.Truncate(header, textWidth, "…"))}
+	for _, line := range lines[scroll:end] {
+		// Reset at the end of every line so colors left open don't leak into the border
+		content = append(content, ansi.Truncate(line, textWidth, "")+ansiReset)
+	}
+	return styles.PreviewContainerStyle.
+		Width(containerWidth).
+		Height(height).
+		Render(strings.Join(content, "\n"))
+}
+
+// ansiReset turns off every color and text attribute
+const ansiReset = "\x1b[0m"
+
+// PreviewContentWidth returns the width of the text inside a preview pane given width columns
+func PreviewContentWidth(width, horizontalMargin int) int {
+	// Inside the margins, the border and the container's padding
+	return max(width-(horizontalMargin*2)-2-2, 1)
+}
+
+// PreviewOutputHeight returns how many lines of preview command output fit in a pane
+// whose content is height lines tall, below the header
+func PreviewOutputHeight(height int) int {
+	return max(height-1, 1)
+}
+
+// previewDetail renders one labeled detail line of the preview pane
+func previewDetail(label, value string) string {
+	return styles.PreviewLabelStyle.Render(label) + styles.PreviewValueStyle.Render(value)
+}
+
+// prettyCommand breaks a one-line command that's wider than width onto several lines:
+// after ; and &, and before the pipes, && and || with a backslash continuation, so the
+// result still runs the same way. Commands that fit or already span lines are kept.
+func prettyCommand(text string, width int) string {
+	if strings.Contains(text, "\n") || lipgloss.Width(text) <= width {
+		return text
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, span := range history.ShellSyntax(text) {
+		if span.Kind != history.SyntaxOperator {
+			continue
+		}
+		switch text[span.Start:span.End] {
+		case "|", "|&", "||", "&&":
+			b.WriteString(strings.TrimRight(text[last:span.Start], " \t"))
+			b.WriteString(" \\\n  ")
+			last = span.Start
+		case ";", "&":
+			b.WriteString(text[last:span.End])
+			b.WriteString("\n")
+			last = span.End
+			for last < len(text) && (text[last] == ' ' || text[last] == '\t') {
+				last++
+			}
+		}
+	}
+	b.WriteString(text[last:])
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -13,19 +13,29 @@ const (
 	SearchPanelWidthRatio = 0.85
 )
 
-// RenderSearchComponent renders a two-column search bar with input and mode badge
-func RenderSearchComponent(prompt, inputValue, mode string, terminalWidth int, horizontalMargin int) string {
+// RenderSearchComponent renders a two-column search bar with input and mode badge.
+// invalid shows the badge in its error style, for a query that can't be searched.
+func RenderSearchComponent(prompt, inputValue, mode string, invalid bool, terminalWidth int, horizontalMargin int) string {
 	usableWidth := terminalWidth - (horizontalMargin * 2)
 	contentWidth := usableWidth - 4
 
+	modeText := fmt.Sprintf("[%s]", mode)
+
+	// Grow the badge when its text doesn't fit the default ratio
 	inputWidth := int(float64(contentWidth) * SearchPanelWidthRatio)
+	if badgeWidth := lipgloss.Width(modeText) + 2; contentWidth-inputWidth < badgeWidth {
+		inputWidth = contentWidth - badgeWidth
+	}
 	modeWidth := contentWidth - inputWidth
 
 	leftContent := styles.PromptStyle.Render(prompt) + inputValue
 	leftPanel := styles.SearchInputStyle.Width(inputWidth).Render(leftContent)
 
-	modeText := fmt.Sprintf("[%s]", mode)
-	rightPanel := styles.ModeBadgeStyle.Width(modeWidth).Render(modeText)
+	badgeStyle := styles.ModeBadgeStyle
+	if invalid {
+		badgeStyle = styles.ModeBadgeErrorStyle
+	}
+	rightPanel := badgeStyle.Width(modeWidth).Render(modeText)
 
 	searchBar := lipgloss.JoinHorizontal(lipgloss.Top, leftPanel, rightPanel)
 	return styles.SearchContainerStyle.Render(searchBar)
@@ -1,6 +1,8 @@
 package tui
 
 import (
+	"context"
+
 	"sheek/internal/config"
 	"sheek/internal/history"
 	"sheek/internal/tui/components"
@@ -14,15 +16,38 @@ type Model struct {
 	Input            textinput.Model
 	List             list.Model
 	Commands         []history.Command
+	Searcher         *history.Searcher // Searches Commands through an index, nil until the index is built
 	FilteredCommands []history.Command
-	FuzzyPositions   map[int][]int // Map command index -> match positions for fuzzy highlighting
+	FuzzyPositions   map[int][]int    // Map command index -> match positions for fuzzy and approx highlighting
+	FilteredQuery    string           // Query FilteredCommands were found for, used for highlighting
+	FilteredMode     SearchMode       // Search mode FilteredQuery was matched in
+	FilteredCase     history.CaseMode // Case mode FilteredQuery was matched in
+	SearchErr        error            // Why the current query can't be searched, like an invalid regex
+	Searching        bool             // A background search for the current query hasn't finished yet
 	SearchMode       SearchMode
+	CaseMode         history.CaseMode
+	SortMode         history.SortMode
+	DedupeMode       history.DedupeMode
+	Usage            history.UsageStats // Occurrence counts and first/last use per unique command
+	ShowPreview      bool               // Show the selected command's details in the preview pane
+	PreviewLines     []string           // Output of the preview command for the selected command
+	PreviewScroll    int                // First line of PreviewLines shown in the preview pane
+	PreviewRunning   bool               // The preview command for the selected command hasn't finished yet
+	PreviewErr       error              // Why the preview command failed, like a non-zero exit status
 	Width            int
 	Height           int
 	SelectedCommand  string // Command selected when user presses Enter
 	LinesRendered    int    // Number of lines rendered by the UI (for cleanup)
 	Placeholder      string
 	Config           *config.Config // Application configuration
+
+	searchID     int                // Identifies the latest search so stale results are dropped
+	cancelSearch context.CancelFunc // Cancels the running background search
+	unindexed    []history.Command  // Commands appended before the search index was built
+
+	previewID     int                // Identifies the latest preview so stale output is dropped
+	cancelPreview context.CancelFunc // Stops the running preview command
+	previewIndex  int                // Index of the command previewed, or -1 for none
 }
 
 // NewModel creates a new Model with the given commands, config, and optional initial query
@@ -53,21 +78,40 @@ func NewModel(commands []history.Command, cfg *config.Config, initialQuery strin
 
 	// Determine initial search mode from config
 	initialSearchMode := SearchModeExact
-	if cfg.Mode == "fuzzy" {
+	switch cfg.Mode {
+	case "fuzzy":
 		initialSearchMode = SearchModeFuzzy
+	case "approx":
+		initialSearchMode = SearchModeApprox
+	case "regex":
+		initialSearchMode = SearchModeRegex
 	}
 
+	initialCaseMode, _ := history.ParseCaseMode(cfg.Case)
+	initialSortMode, _ := history.ParseSortMode(cfg.Sort)
+	initialDedupeMode, _ := history.ParseDedupeMode(cfg.Dedupe)
+
 	model := Model{
 		Input:            in,
 		List:             l,
 		Commands:         commands,
 		FilteredCommands: commands,
 		SearchMode:       initialSearchMode,
+		FilteredMode:     initialSearchMode,
+		CaseMode:         initialCaseMode,
+		FilteredCase:     initialCaseMode,
+		SortMode:         initialSortMode,
+		DedupeMode:       initialDedupeMode,
+		Usage:            history.NewUsageStats(commands),
+		ShowPreview:      cfg.Preview,
 		Placeholder:      cfg.Placeholder,
 		Config:           cfg,
+		previewIndex:     -1,
 	}
 
-	model = updateSearchResults(model)
+	// Search synchronously so the first frame already shows results
+	results, _ := runSearch(context.Background(), newSearchRequest(model, -1))
+	model = applySearchResults(model, results)
 
 	return model
 }
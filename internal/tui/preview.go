@@ -0,0 +1,225 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"sheek/internal/history"
+	"sheek/internal/tui/components"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	// maxPreviewOutput is how much output of a preview command is kept, in bytes.
+	// A command that writes more is stopped, so something like "yes" can't run forever.
+	maxPreviewOutput = 1 << 20
+	// previewWaitDelay is how long a cancelled preview command's output may stay open,
+	// for instance by a background process it started, before it's closed anyway
+	previewWaitDelay = 100 * time.Millisecond
+)
+
+// errPreviewOutputFull stops a preview command once maxPreviewOutput is reached
+var errPreviewOutputFull = errors.New("preview output is full")
+
+// previewPlaceholder matches the placeholders of a preview command: {} for the whole
+// command, {n} for its nth word, {-n} for the nth word from the end, and ranges of
+// words like {2..}, {..3} or {1..-2}
+var previewPlaceholder = regexp.MustCompile(`\{(-?[0-9]+)?(\.\.(-?[0-9]+)?)?\}`)
+
+// previewOutputMsg delivers the output of a background preview command to Update
+type previewOutputMsg struct {
+	id    int
+	lines []string
+	err   error
+}
+
+// syncPreview makes the running preview match the selection: when a different command
+// gets selected, or the preview pane is shown or hidden, it cancels the running preview
+// command, if any, and returns a command that runs the preview for the new selection.
+func syncPreview(model Model) (Model, tea.Cmd) {
+	want := -1
+	if model.ShowPreview && model.Config.PreviewCommand != "" {
+		if cmd := selectedCommand(model); cmd != nil {
+			want = cmd.Index
+		}
+	}
+	if want == model.previewIndex {
+		return model, nil
+	}
+
+	model = stopPreview(model)
+	model.previewIndex = want
+	model.PreviewLines, model.PreviewScroll, model.PreviewErr = nil, 0, nil
+	if want < 0 {
+		return model, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	model.cancelPreview = cancel
+	model.previewID++
+	model.PreviewRunning = true
+
+	id := model.previewID
+	command := expandPreviewCommand(model.Config.PreviewCommand, selectedCommand(model).Text)
+	width, height := previewPane(model)
+	columns := components.PreviewContentWidth(width, model.Config.Margin)
+	return model, func() tea.Msg {
+		defer cancel()
+		lines, err := runPreview(ctx, command, columns, components.PreviewOutputHeight(height))
+		if ctx.Err() != nil {
+			// Superseded by the preview of a newer selection
+			return nil
+		}
+		return previewOutputMsg{id: id, lines: lines, err: err}
+	}
+}
+
+// stopPreview cancels the running preview command, if any
+func stopPreview(model Model) Model {
+	if model.cancelPreview != nil {
+		model.cancelPreview()
+		model.cancelPreview = nil
+	}
+	model.PreviewRunning = false
+	return model
+}
+
+// applyPreviewOutput shows the output of the latest preview command and drops stale output
+func applyPreviewOutput(model Model, msg previewOutputMsg) Model {
+	if msg.id != model.previewID {
+		return model
+	}
+	model.cancelPreview = nil
+	model.PreviewRunning = false
+	model.PreviewLines = msg.lines
+	model.PreviewErr = msg.err
+	return model
+}
+
+// scrollPreview moves the preview output by delta lines, keeping the last page in view
+func scrollPreview(model Model, delta int) Model {
+	_, height := previewPane(model)
+	last := max(len(model.PreviewLines)-components.PreviewOutputHeight(height), 0)
+	model.PreviewScroll = max(min(model.PreviewScroll+delta, last), 0)
+	return model
+}
+
+// runPreview runs command with sh and returns the lines of its output and error output.
+// The size of the preview pane is passed in $COLUMNS and $LINES, which programs like man
+// format their output for. The command is killed once ctx is done.
+func runPreview(ctx context.Context, command string, columns, lines int) ([]string, error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = append(os.Environ(), "COLUMNS="+strconv.Itoa(columns), "LINES="+strconv.Itoa(lines))
+	cmd.WaitDelay = previewWaitDelay
+
+	output := &previewBuffer{}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	err := cmd.Run()
+	if output.full {
+		// Stopping the command is expected, however it reports it
+		err = nil
+	}
+	return previewLines(output.String()), err
+}
+
+// previewBuffer collects the output of a preview command up to maxPreviewOutput bytes
+type previewBuffer struct {
+	strings.Builder
+	full bool // Output was dropped and the command stopped
+}
+
+// Write appends p, or fails with errPreviewOutputFull once the buffer is full
+func (b *previewBuffer) Write(p []byte) (int, error) {
+	room := maxPreviewOutput - b.Len()
+	if len(p) > room {
+		b.Builder.Write(p[:room])
+		b.full = true
+		return room, errPreviewOutputFull
+	}
+	return b.Builder.Write(p)
+}
+
+// previewLines splits command output into lines for the preview pane. Tabs become
+// spaces and carriage returns are dropped, since the pane can't place the cursor.
+func previewLines(output string) []string {
+	output = strings.TrimRight(output, "\n")
+	if output == "" {
+		return nil
+	}
+	output = strings.NewReplacer("\r", "", "\t", "    ").Replace(output)
+	return strings.Split(output, "\n")
+}
+
+// expandPreviewCommand substitutes the placeholders of a preview command with the words
+// of text, shell-quoted. Words are split like ParseCommandLine does, without the variable
+// assignments, redirections and operators between commands. Words out of range expand
+// to an empty quoted word.
+func expandPreviewCommand(template, text string) string {
+	var words []string
+	for _, cmd := range history.ParseCommandLine(text) {
+		words = append(words, cmd.Program.Text)
+		for _, arg := range cmd.Args {
+			words = append(words, arg.Text)
+		}
+	}
+
+	return previewPlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		m := previewPlaceholder.FindStringSubmatch(placeholder)
+		start, isRange, end := m[1], m[2] != "", m[3]
+		if start == "" && !isRange {
+			return history.ShellQuote(text)
+		}
+
+		first, last := 0, len(words)-1
+		if start != "" {
+			var ok bool
+			if first, ok = wordIndex(start, len(words)); !ok {
+				return "''"
+			}
+		}
+		if !isRange {
+			if first < 0 || first >= len(words) {
+				return "''"
+			}
+			return history.ShellQuote(words[first])
+		}
+		if end != "" {
+			var ok bool
+			if last, ok = wordIndex(end, len(words)); !ok {
+				return "''"
+			}
+		}
+		first, last = max(first, 0), min(last, len(words)-1)
+		if first > last {
+			return "''"
+		}
+
+		quoted := make([]string, 0, last-first+1)
+		for _, word := range words[first : last+1] {
+			quoted = append(quoted, history.ShellQuote(word))
+		}
+		return strings.Join(quoted, " ")
+	})
+}
+
+// wordIndex converts a 1-based word number, counted from the end if negative, to an index
+// into n words. Numbers past either end give an index out of range, which a range of
+// words stops at, but 0 numbers no word from either end, so it reports false.
+func wordIndex(number string, n int) (int, bool) {
+	i, err := strconv.Atoi(number)
+	switch {
+	case i == 0 || err != nil && !errors.Is(err, strconv.ErrRange):
+		return 0, false
+	case i < 0:
+		return n + i, true
+	}
+	return i - 1, true // Atoi clamps numbers too large for an int, which stay past the end
+}
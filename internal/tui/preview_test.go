@@ -0,0 +1,113 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"sheek/internal/history"
+)
+
+func TestWordIndex(t *testing.T) {
+	tests := []struct {
+		number string
+		n      int
+		want   int
+		ok     bool
+	}{
+		{"1", 3, 0, true},
+		{"3", 3, 2, true},
+		{"4", 3, 3, true},
+		{"-1", 3, 2, true},
+		{"-3", 3, 0, true},
+		{"-4", 3, -1, true},
+		{"0", 3, 0, false},
+		{"-0", 3, 0, false},
+		{"99999999999999999999", 3, math.MaxInt - 1, true},
+		{"-99999999999999999999", 3, math.MinInt + 3, true},
+	}
+
+	for _, tt := range tests {
+		if got, ok := wordIndex(tt.number, tt.n); got != tt.want || ok != tt.ok {
+			t.Errorf("wordIndex(%q, %d) = %d, %v, want %d, %v", tt.number, tt.n, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestExpandPreviewCommand(t *testing.T) {
+	tests := []struct {
+		template string
+		text     string
+		want     string
+	}{
+		{"man {1}", "git commit -m fix", "man git"},
+		{"echo {}", "git commit -m fix", "echo 'git commit -m fix'"},
+		{"echo {-1}", "git commit -m fix", "echo fix"},
+		{"echo {2..}", "git commit -m fix", "echo commit -m fix"},
+		{"echo {..3}", "git commit -m fix", "echo git commit -m"},
+		{"echo {2..-2}", "git commit -m fix", "echo commit -m"},
+		{"echo {5} {0} {-5}", "git commit -m fix", "echo '' '' ''"},
+		{"echo {5..} {3..2}", "git commit -m fix", "echo '' ''"},
+		{"echo {0}", "git commit -m fix", "echo ''"},
+		{"echo {0..}", "git commit -m fix", "echo ''"},
+		{"echo {..0}", "git commit -m fix", "echo ''"},
+		{"echo {0..-1} {2..0}", "git commit -m fix", "echo '' ''"},
+		{"echo {-99..2} {3..99}", "git commit -m fix", "echo git commit -m fix"},
+		{"echo {2..99999999999999999999}", "git commit -m fix", "echo commit -m fix"},
+		{"echo {1}", "", "echo ''"},
+		{"awk '{print $1}' {x} {{}", "ls", "awk '{print $1}' {x} {ls"},
+		{"echo {-1}", `git commit -m "it's done"`, `echo 'it'\''s done'`},
+		{"echo {}", "echo $(id); ls", "echo 'echo $(id); ls'"},
+		{"echo {..}", "echo $(id); ls", "echo echo '$(id)' ls"},
+	}
+
+	for _, tt := range tests {
+		if got := expandPreviewCommand(tt.template, tt.text); got != tt.want {
+			t.Errorf("expandPreviewCommand(%q, %q) = %q, want %q", tt.template, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestExpandPreviewCommandQuoting(t *testing.T) {
+	// Each expanded word must reach the shell as exactly one argument, so printing the
+	// arguments gives back the command and its words, and nothing in them gets run:
+	// running any of the touch commands would create the marker file
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "pwned")
+	t.Setenv("DIR", dir)
+	texts := []string{
+		`echo 'it'"'"'s'`,
+		`echo $(touch "$DIR/pwned"); touch $DIR/pwned`,
+		`x'; touch "$DIR/pwned"; echo '`,
+		"a `touch $DIR/pwned` ${HOME} \\$ \"$(id)\" |& b",
+		`'\''`,
+	}
+
+	for _, text := range texts {
+		want := []string{text}
+		for _, cmd := range history.ParseCommandLine(text) {
+			want = append(want, cmd.Program.Text)
+			for _, arg := range cmd.Args {
+				want = append(want, arg.Text)
+			}
+		}
+
+		command := expandPreviewCommand(`printf '%s\n' {} {..}`, text)
+		got, err := runPreview(context.Background(), command, 80, 24)
+		if err != nil {
+			t.Errorf("running %q: %v", command, err)
+			continue
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("%q printed %q, want %q", command, got, want)
+		}
+	}
+	if _, err := os.Stat(marker); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("a preview command ran part of the command it was given (stat %s: %v)", marker, err)
+	}
+}
@@ -0,0 +1,263 @@
+package tui
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"sheek/internal/history"
+	"sheek/internal/tui/components"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// searchKey holds the inputs that decide the search results; a change starts a new search
+type searchKey struct {
+	query    string
+	mode     SearchMode
+	caseMode history.CaseMode
+	sort     history.SortMode
+	dedupe   history.DedupeMode
+}
+
+// currentSearchKey returns the search inputs of the model
+func currentSearchKey(model Model) searchKey {
+	return searchKey{
+		query:    model.Input.Value(),
+		mode:     model.SearchMode,
+		caseMode: model.CaseMode,
+		sort:     model.SortMode,
+		dedupe:   model.DedupeMode,
+	}
+}
+
+// searchRequest is a snapshot of everything a search needs, so it can run off the UI goroutine.
+// The model never mutates Commands, Searcher or Usage in place, so sharing them is safe.
+type searchRequest struct {
+	id       int
+	key      searchKey
+	commands []history.Command
+	searcher *history.Searcher // Searched instead of commands once built
+	reverse  bool              // commands are newest first, the reverse of the searcher's
+	usage    history.UsageStats
+	frecency history.FrecencyWeights
+	follow   int       // Index of the command to keep selected, or -1
+	now      time.Time // Reference time for time range tokens like within:2h
+}
+
+// searchResultsMsg delivers the results of a background search to Update
+type searchResultsMsg struct {
+	id        int
+	query     string
+	mode      SearchMode
+	caseMode  history.CaseMode
+	err       error // The query couldn't be searched; the previous results stay
+	commands  []history.Command
+	positions map[int][]int
+	follow    int
+}
+
+// startSearch cancels the running search, if any, and returns a command that searches
+// in the background. follow is the Index of the command to select once the results
+// arrive, or -1 to keep the list position.
+func startSearch(model Model, follow int) (Model, tea.Cmd) {
+	if model.cancelSearch != nil {
+		model.cancelSearch()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	model.cancelSearch = cancel
+	model.searchID++
+	model.Searching = true
+
+	req := newSearchRequest(model, follow)
+	return model, func() tea.Msg {
+		defer cancel()
+		results, err := runSearch(ctx, req)
+		if err != nil {
+			// Superseded by a newer search, which will report instead
+			return nil
+		}
+		return results
+	}
+}
+
+// newSearchRequest snapshots the model for runSearch
+func newSearchRequest(model Model, follow int) searchRequest {
+	return searchRequest{
+		id:       model.searchID,
+		key:      currentSearchKey(model),
+		commands: model.Commands,
+		searcher: model.Searcher,
+		reverse:  model.Config.Reverse,
+		usage:    model.Usage,
+		frecency: frecencyWeights(model.Config.Frecency),
+		follow:   follow,
+		now:      time.Now(),
+	}
+}
+
+// runSearch filters, dedupes and sorts the commands of req. Outside regex mode, time
+// range tokens like after:2025-06-01 are taken out of the query and filter the matches
+// by timestamp. A query that can't be searched, like an invalid regex or date, is
+// reported in the results' err.
+// It returns ctx's error as soon as it notices ctx has been cancelled.
+func runSearch(ctx context.Context, req searchRequest) (searchResultsMsg, error) {
+	results := searchResultsMsg{id: req.id, query: req.key.query, mode: req.key.mode, caseMode: req.key.caseMode, follow: req.follow}
+
+	query := req.key.query
+	var timeRange history.TimeRange
+	if req.key.mode != SearchModeRegex {
+		var parseErr error
+		timeRange, query, parseErr = history.ParseTimeRange(query, req.now)
+		if parseErr != nil {
+			results.err = parseErr
+			return results, nil
+		}
+		results.query = query // Highlight the text terms only
+	}
+
+	var filtered []history.Command
+	var err error
+	switch {
+	case req.key.mode == SearchModeRegex && req.key.query == "":
+		filtered = req.commands
+	case req.key.mode == SearchModeRegex:
+		re, compileErr := history.CompileRegex(req.key.query, req.key.caseMode)
+		if compileErr != nil {
+			results.err = compileErr
+			return results, nil
+		}
+		if req.searcher != nil {
+			filtered, err = req.searcher.SearchRegex(ctx, re)
+			if req.reverse {
+				filtered = slices.Clone(filtered)
+				slices.Reverse(filtered)
+			}
+		} else {
+			filtered, err = history.SearchRegexContext(ctx, req.commands, re)
+		}
+	case history.ParseQuery(query, history.MatchExact, req.key.caseMode).Empty():
+		// Nothing to match, like a blank query or lone operators
+		filtered = req.commands
+	case req.key.mode == SearchModeFuzzy:
+		var fuzzyResults []history.FuzzyMatchResult
+		if req.searcher != nil {
+			fuzzyResults, err = req.searcher.SearchFuzzyWithPositions(ctx, query, req.key.caseMode)
+		} else {
+			fuzzyResults, err = history.SearchFuzzyWithPositionsContext(ctx, req.commands, query, req.key.caseMode)
+		}
+		filtered, results.positions = positionsByIndex(fuzzyResults)
+	case req.key.mode == SearchModeApprox:
+		var approxResults []history.FuzzyMatchResult
+		if req.searcher != nil {
+			approxResults, err = req.searcher.SearchApproximate(ctx, query, req.key.caseMode)
+		} else {
+			approxResults, err = history.SearchApproximateContext(ctx, req.commands, query, req.key.caseMode)
+		}
+		filtered, results.positions = positionsByIndex(approxResults)
+	case req.searcher != nil:
+		filtered, err = req.searcher.SearchExact(ctx, query, req.key.caseMode)
+		if req.reverse {
+			// The searcher returns its cached slice in history order
+			filtered = slices.Clone(filtered)
+			slices.Reverse(filtered)
+		}
+	default:
+		filtered, err = history.SearchExactContext(ctx, req.commands, query, req.key.caseMode)
+	}
+	if err != nil {
+		return searchResultsMsg{}, err
+	}
+
+	filtered = timeRange.Filter(filtered)
+	filtered = history.Dedupe(filtered, req.key.dedupe)
+	if err := ctx.Err(); err != nil {
+		return searchResultsMsg{}, err
+	}
+	results.commands = history.SortCommands(filtered, req.key.sort, history.SortOptions{
+		Usage:    req.usage,
+		Frecency: req.frecency,
+		Now:      req.now,
+	})
+	return results, nil
+}
+
+// positionsByIndex splits search results into their commands and a map from
+// command index to match positions for highlighting
+func positionsByIndex(matches []history.FuzzyMatchResult) ([]history.Command, map[int][]int) {
+	commands := make([]history.Command, len(matches))
+	positions := make(map[int][]int, len(matches))
+	for i, match := range matches {
+		commands[i] = match.Command
+		positions[match.Command.Index] = match.Positions
+	}
+	return commands, positions
+}
+
+// applySearchResults shows the results of the latest search and ignores stale ones.
+// A search that failed leaves the previous results in place and records its error.
+func applySearchResults(model Model, results searchResultsMsg) Model {
+	if results.id != model.searchID {
+		return model
+	}
+	model.Searching = false
+	model.SearchErr = results.err
+	if results.err != nil {
+		return model
+	}
+	model.FilteredQuery = results.query
+	model.FilteredMode = results.mode
+	model.FilteredCase = results.caseMode
+	model.FuzzyPositions = results.positions
+
+	// Check if search results changed
+	previousCount := len(model.FilteredCommands)
+	searchResultsChanged := previousCount != len(results.commands)
+
+	model.FilteredCommands = results.commands
+	model.List.SetItems(components.CommandsToListItems(results.commands))
+
+	// Adjust list index if search results changed
+	if searchResultsChanged {
+		model = adjustListIndex(model, len(results.commands))
+	}
+
+	// Follow the previously selected command to its new position
+	if results.follow >= 0 {
+		for i, cmd := range model.FilteredCommands {
+			if cmd.Index == results.follow {
+				model.List.Select(i)
+				break
+			}
+		}
+	}
+	return model
+}
+
+// indexBuiltMsg delivers the search index built in the background
+type indexBuiltMsg struct {
+	index *history.Index
+}
+
+// buildIndexCmd indexes the model's commands in the background. Until it's done
+// searches scan the commands linearly.
+func buildIndexCmd(model Model) tea.Cmd {
+	commands, reverse := model.Commands, model.Config.Reverse
+	return func() tea.Msg {
+		if reverse {
+			commands = slices.Clone(commands)
+			slices.Reverse(commands)
+		}
+		return indexBuiltMsg{index: history.NewIndex(commands)}
+	}
+}
+
+// applySearchIndex starts searching through a newly built index, adding the commands appended while it was built
+func applySearchIndex(model Model, index *history.Index) Model {
+	if len(model.unindexed) > 0 {
+		index = index.Append(model.unindexed...)
+		model.unindexed = nil
+	}
+	model.Searcher = history.NewSearcher(index)
+	return model
+}
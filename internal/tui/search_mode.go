@@ -8,6 +8,10 @@ const (
 	SearchModeExact SearchMode = "Exact"
 	// SearchModeFuzzy performs fuzzy matching
 	SearchModeFuzzy SearchMode = "Fuzzy"
+	// SearchModeApprox tolerates a few typos per query word
+	SearchModeApprox SearchMode = "Approx"
+	// SearchModeRegex matches a regular expression
+	SearchModeRegex SearchMode = "Regex"
 )
 
 // String returns the string representation of SearchMode
@@ -15,10 +19,15 @@ func (s SearchMode) String() string {
 	return string(s)
 }
 
-// Toggle switches between Exact and Fuzzy modes
-func (s SearchMode) Toggle() SearchMode {
-	if s == SearchModeExact {
+// Next cycles through the Exact, Fuzzy, Approx and Regex modes
+func (s SearchMode) Next() SearchMode {
+	switch s {
+	case SearchModeExact:
 		return SearchModeFuzzy
+	case SearchModeFuzzy:
+		return SearchModeApprox
+	case SearchModeApprox:
+		return SearchModeRegex
 	}
 	return SearchModeExact
 }
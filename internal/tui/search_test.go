@@ -0,0 +1,107 @@
+package tui
+
+import (
+	"slices"
+	"testing"
+
+	"sheek/internal/config"
+	"sheek/internal/history"
+)
+
+// newTestModel returns a model of commands with the default config, searched linearly
+func newTestModel(commands []history.Command) Model {
+	return NewModel(commands, config.DefaultConfig(), "")
+}
+
+// search types query and runs the search it starts to completion
+func search(model Model, query string) (Model, searchResultsMsg) {
+	model.Input.SetValue(query)
+	model, cmd := startSearch(model, -1)
+	results, _ := cmd().(searchResultsMsg)
+	return model, results
+}
+
+// filteredTexts returns the texts of the commands the model shows
+func filteredTexts(model Model) []string {
+	var texts []string
+	for _, cmd := range model.FilteredCommands {
+		texts = append(texts, cmd.Text)
+	}
+	return texts
+}
+
+var testCommands = []history.Command{
+	{Index: 1, Text: "git status"},
+	{Index: 2, Text: "ls -la"},
+	{Index: 3, Text: "git log"},
+}
+
+func TestStaleSearchResultsAreIgnored(t *testing.T) {
+	model := newTestModel(testCommands)
+	model, older := search(model, "git")
+	model, newer := search(model, "ls")
+
+	model = applySearchResults(model, newer)
+	model = applySearchResults(model, older)
+	if got, want := filteredTexts(model), []string{"ls -la"}; !slices.Equal(got, want) {
+		t.Errorf("after a stale result, FilteredCommands = %q, want %q", got, want)
+	}
+	if model.Searching {
+		t.Error("Searching is still set after the latest results arrived")
+	}
+}
+
+func TestSupersededSearchReturnsNoMessage(t *testing.T) {
+	model := newTestModel(testCommands)
+	model.Input.SetValue("git")
+	model, superseded := startSearch(model, -1)
+	model.Input.SetValue("ls")
+	_, latest := startSearch(model, -1)
+
+	if msg := superseded(); msg != nil {
+		t.Errorf("superseded search returned %#v, want no message", msg)
+	}
+	if _, ok := latest().(searchResultsMsg); !ok {
+		t.Error("latest search returned no results")
+	}
+}
+
+func TestTickDoesNotSearch(t *testing.T) {
+	model := newTestModel(testCommands)
+	searchID := model.searchID
+
+	model, _ = Update(tickMsg{}, model)
+	if model.searchID != searchID || model.Searching {
+		t.Errorf("tick started a search: searchID %d -> %d, Searching %v", searchID, model.searchID, model.Searching)
+	}
+}
+
+func TestInvalidRegexKeepsResults(t *testing.T) {
+	model := newTestModel(testCommands)
+	model.SearchMode = SearchModeRegex
+
+	model, valid := search(model, "^git")
+	model = applySearchResults(model, valid)
+	want := filteredTexts(model)
+	if !slices.Equal(want, []string{"git status", "git log"}) {
+		t.Fatalf("FilteredCommands for ^git = %q", want)
+	}
+
+	model, invalid := search(model, "^git (")
+	model = applySearchResults(model, invalid)
+	if got := filteredTexts(model); !slices.Equal(got, want) {
+		t.Errorf("after an invalid pattern, FilteredCommands = %q, want %q", got, want)
+	}
+	if model.SearchErr == nil {
+		t.Error("SearchErr isn't set for an invalid pattern")
+	}
+	if model.FilteredQuery != "^git" {
+		t.Errorf("FilteredQuery = %q, want the last valid pattern %q", model.FilteredQuery, "^git")
+	}
+
+	model, fixed := search(model, "^git (log)")
+	model = applySearchResults(model, fixed)
+	if model.SearchErr != nil {
+		t.Errorf("SearchErr = %v after the pattern was fixed", model.SearchErr)
+	}
+}
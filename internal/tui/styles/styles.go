@@ -16,6 +16,13 @@ var (
 	selectedColor  lipgloss.Color
 	accentColor    lipgloss.Color
 	highlightColor lipgloss.Color
+	errorColor     lipgloss.Color
+	programColor   lipgloss.Color
+	flagColor      lipgloss.Color
+	stringColor    lipgloss.Color
+	variableColor  lipgloss.Color
+	operatorColor  lipgloss.Color
+	commentColor   lipgloss.Color
 	MutedColor     lipgloss.Color // Exported for external use
 )
 
@@ -25,6 +32,7 @@ var (
 	SearchInputStyle       lipgloss.Style
 	SearchPlaceholderStyle lipgloss.Style
 	ModeBadgeStyle         lipgloss.Style
+	ModeBadgeErrorStyle    lipgloss.Style
 	SearchContainerStyle   lipgloss.Style
 	ListContainerStyle     lipgloss.Style
 	ListItemStyle          lipgloss.Style
@@ -33,8 +41,21 @@ var (
 	HighlightSelectedStyle lipgloss.Style
 	ItemNumberStyle        lipgloss.Style
 	TimestampStyle         lipgloss.Style
+	DurationStyle          lipgloss.Style
+	CountBadgeStyle        lipgloss.Style
 	CommandTextStyle       lipgloss.Style
+	SyntaxPlainStyle       lipgloss.Style
+	SyntaxProgramStyle     lipgloss.Style
+	SyntaxFlagStyle        lipgloss.Style
+	SyntaxStringStyle      lipgloss.Style
+	SyntaxVariableStyle    lipgloss.Style
+	SyntaxOperatorStyle    lipgloss.Style
+	SyntaxCommentStyle     lipgloss.Style
 	EmptyStateStyle        lipgloss.Style
+	PreviewContainerStyle  lipgloss.Style
+	PreviewLabelStyle      lipgloss.Style
+	PreviewValueStyle      lipgloss.Style
+	PreviewHeaderStyle     lipgloss.Style
 	ScrollbarTrackStyle    lipgloss.Style
 	ScrollbarThumbStyle    lipgloss.Style
 )
@@ -50,6 +71,13 @@ func InitializeStyles(cfg *config.Config) {
 	selectedColor = lipgloss.Color(cfg.Colors.Selected)
 	accentColor = lipgloss.Color(cfg.Colors.Highlight)
 	highlightColor = lipgloss.Color(cfg.Colors.Highlight)
+	errorColor = lipgloss.Color(cfg.Colors.Error)
+	programColor = lipgloss.Color(cfg.Colors.Program)
+	flagColor = lipgloss.Color(cfg.Colors.Flag)
+	stringColor = lipgloss.Color(cfg.Colors.String)
+	variableColor = lipgloss.Color(cfg.Colors.Variable)
+	operatorColor = lipgloss.Color(cfg.Colors.Operator)
+	commentColor = lipgloss.Color(cfg.Colors.Comment)
 	MutedColor = mutedColor
 
 	// Initialize all component styles
@@ -68,6 +96,9 @@ func InitializeStyles(cfg *config.Config) {
 		Foreground(secondaryColor).
 		Bold(true).PaddingLeft(1).PaddingRight(1).Height(1).
 		Align(lipgloss.Center)
+	ModeBadgeErrorStyle = ModeBadgeStyle.
+		BorderForeground(errorColor).
+		Foreground(errorColor)
 
 	SearchContainerStyle = lipgloss.NewStyle().MarginLeft(1).MarginRight(1).MarginTop(1)
 
@@ -96,10 +127,34 @@ func InitializeStyles(cfg *config.Config) {
 		Width(7).
 		Align(lipgloss.Right).
 		MarginRight(1)
+	DurationStyle = lipgloss.NewStyle().
+		Foreground(secondaryColor).
+		Width(7).
+		Align(lipgloss.Right).
+		MarginRight(1)
+	CountBadgeStyle = lipgloss.NewStyle().
+		Foreground(primaryColor).
+		Bold(true).
+		Width(5).
+		Align(lipgloss.Right)
 	CommandTextStyle = lipgloss.NewStyle().Foreground(textColor).MarginLeft(1)
 
+	// Shell syntax of the command text, see history.ShellSyntax
+	SyntaxPlainStyle = lipgloss.NewStyle().Foreground(textColor)
+	SyntaxProgramStyle = lipgloss.NewStyle().Foreground(programColor).Bold(true)
+	SyntaxFlagStyle = lipgloss.NewStyle().Foreground(flagColor)
+	SyntaxStringStyle = lipgloss.NewStyle().Foreground(stringColor)
+	SyntaxVariableStyle = lipgloss.NewStyle().Foreground(variableColor)
+	SyntaxOperatorStyle = lipgloss.NewStyle().Foreground(operatorColor).Bold(true)
+	SyntaxCommentStyle = lipgloss.NewStyle().Foreground(commentColor).Italic(true)
+
 	EmptyStateStyle = lipgloss.NewStyle().Foreground(mutedColor).Align(lipgloss.Center).Padding(2)
 
+	PreviewContainerStyle = ListContainerStyle
+	PreviewLabelStyle = lipgloss.NewStyle().Foreground(mutedColor).Width(10)
+	PreviewValueStyle = lipgloss.NewStyle().Foreground(textColor)
+	PreviewHeaderStyle = lipgloss.NewStyle().Foreground(mutedColor)
+
 	ScrollbarTrackStyle = lipgloss.NewStyle().Foreground(mutedColor).Width(1)
 
 	ScrollbarThumbStyle = lipgloss.NewStyle().Foreground(primaryColor).Width(1)
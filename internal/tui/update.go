@@ -1,8 +1,10 @@
 package tui
 
 import (
+	"maps"
 	"time"
 
+	"sheek/internal/config"
 	"sheek/internal/history"
 	"sheek/internal/tui/components"
 
@@ -13,6 +15,16 @@ const relativeTimeRefreshInterval = time.Second
 
 type tickMsg struct{}
 
+// HistoryAppendedMsg carries commands appended to the history file while sheek is open
+type HistoryAppendedMsg struct {
+	Commands []history.Command
+}
+
+// Init returns the commands to run at startup: the periodic tick and building the search index
+func Init(model Model) tea.Cmd {
+	return tea.Batch(newTickCmd(), buildIndexCmd(model))
+}
+
 // TickCmd returns a command that schedules periodic ticks for refreshing relative timestamps.
 func TickCmd() tea.Cmd {
 	return newTickCmd()
@@ -24,19 +36,43 @@ func newTickCmd() tea.Cmd {
 	})
 }
 
-// Update handles application updates based on messages
+// Update handles application updates based on messages.
+// Once a message is handled, the preview command follows the selection.
 func Update(msg tea.Msg, model Model) (Model, tea.Cmd) {
+	model, cmd := update(msg, model)
+	model, previewCmd := syncPreview(model)
+	return model, tea.Batch(cmd, previewCmd)
+}
+
+// update handles a message without regard to the preview command
+func update(msg tea.Msg, model Model) (Model, tea.Cmd) {
 	var cmds []tea.Cmd
+	previousSearch := currentSearchKey(model)
 
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		model = handleWindowResize(model, msg)
 	case tea.KeyMsg:
 		if shouldQuit(msg.String()) {
-			return model, tea.Quit
+			return stopPreview(model), tea.Quit
 		}
 		if msg.String() == "tab" {
-			model.SearchMode = model.SearchMode.Toggle()
+			model.SearchMode = model.SearchMode.Next()
+		}
+		if msg.String() == "ctrl+t" {
+			model.CaseMode = model.CaseMode.Next()
+		}
+		if msg.String() == "ctrl+s" {
+			model.SortMode = model.SortMode.Next()
+		}
+		if msg.String() == "ctrl+r" {
+			model.DedupeMode = model.DedupeMode.Next()
+		}
+		if msg.String() == "ctrl+o" {
+			model.ShowPreview = !model.ShowPreview
+		}
+		if delta, ok := previewScrollDelta(model, msg.String()); ok {
+			model = scrollPreview(model, delta)
 		}
 		if msg.String() == "enter" {
 			return handleEnterKey(model)
@@ -47,7 +83,16 @@ func Update(msg tea.Msg, model Model) (Model, tea.Cmd) {
 			cmds = append(cmds, listCmd)
 		}
 	case tickMsg:
-		cmds = append(cmds, newTickCmd())
+		// Only relative timestamps change, which the next render picks up
+		return model, newTickCmd()
+	case HistoryAppendedMsg:
+		return appendCommands(model, msg.Commands)
+	case searchResultsMsg:
+		return applySearchResults(model, msg), nil
+	case indexBuiltMsg:
+		return applySearchIndex(model, msg.index), nil
+	case previewOutputMsg:
+		return applyPreviewOutput(model, msg), nil
 	}
 
 	// Update input
@@ -55,12 +100,47 @@ func Update(msg tea.Msg, model Model) (Model, tea.Cmd) {
 	model.Input, cmd = model.Input.Update(msg)
 	cmds = append(cmds, cmd)
 
-	// Update search results
-	model = updateSearchResults(model)
+	// Search again in the background if the query or a mode changed
+	if currentSearchKey(model) != previousSearch {
+		model, cmd = startSearch(model, -1)
+		cmds = append(cmds, cmd)
+	}
 
 	return model, tea.Batch(cmds...)
 }
 
+// appendCommands adds newly recorded commands while keeping the query and the selected command
+func appendCommands(model Model, appended []history.Command) (Model, tea.Cmd) {
+	selectedIndex := -1
+	if i := model.List.Index(); i >= 0 && i < len(model.FilteredCommands) {
+		selectedIndex = model.FilteredCommands[i].Index
+	}
+
+	commands := make([]history.Command, 0, len(model.Commands)+len(appended))
+	if model.Config.Reverse {
+		// Newest first: appended commands go on top, newest of them first
+		for i := len(appended) - 1; i >= 0; i-- {
+			commands = append(commands, appended[i])
+		}
+		commands = append(commands, model.Commands...)
+	} else {
+		commands = append(commands, model.Commands...)
+		commands = append(commands, appended...)
+	}
+	model.Commands = commands
+	if model.Searcher != nil {
+		model.Searcher = model.Searcher.Append(appended...)
+	} else {
+		model.unindexed = append(model.unindexed, appended...)
+	}
+
+	// A running search may still read the old stats, so add to a copy of them
+	model.Usage = maps.Clone(model.Usage)
+	model.Usage.Add(appended...)
+
+	return startSearch(model, selectedIndex)
+}
+
 // handleWindowResize updates model dimensions when window is resized
 func handleWindowResize(model Model, msg tea.WindowSizeMsg) Model {
 	model.Width = msg.Width
@@ -69,6 +149,24 @@ func handleWindowResize(model Model, msg tea.WindowSizeMsg) Model {
 	return model
 }
 
+// previewScrollDelta returns how many lines key scrolls the preview command's output,
+// or false if it isn't a scroll key. Page up and down move by the lines the pane shows.
+func previewScrollDelta(model Model, key string) (int, bool) {
+	_, height := previewPane(model)
+	page := components.PreviewOutputHeight(height)
+	switch key {
+	case "shift+up":
+		return -1, true
+	case "shift+down":
+		return 1, true
+	case "pgup":
+		return -page, true
+	case "pgdown":
+		return page, true
+	}
+	return 0, false
+}
+
 // shouldQuit returns true if the key should quit the application
 func shouldQuit(key string) bool {
 	return key == "ctrl+c" || key == "esc"
@@ -90,45 +188,16 @@ func handleEnterKey(model Model) (Model, tea.Cmd) {
 		}
 	}
 	// Quit the program - main.go will handle printing the selected command
-	return model, tea.Quit
+	return stopPreview(model), tea.Quit
 }
 
-// updateSearchResults performs search filtering and updates the model
-func updateSearchResults(model Model) Model {
-	inputValue := model.Input.Value()
-	var filtered []history.Command
-
-	switch model.SearchMode {
-	case SearchModeExact:
-		filtered = history.SearchExact(model.Commands, inputValue)
-		model.FuzzyPositions = nil // Clear fuzzy positions for exact search
-	case SearchModeFuzzy:
-		fuzzyResults := history.SearchFuzzyWithPositions(model.Commands, inputValue)
-		// Build map from command index to match positions
-		model.FuzzyPositions = make(map[int][]int, len(fuzzyResults))
-		filtered = make([]history.Command, len(fuzzyResults))
-		for i, result := range fuzzyResults {
-			filtered[i] = result.Command
-			model.FuzzyPositions[result.Command.Index] = result.Positions
-		}
-	default:
-		filtered = history.SearchExact(model.Commands, inputValue)
-		model.FuzzyPositions = nil
+// frecencyWeights converts the configured frecency weights for the history package
+func frecencyWeights(cfg config.FrecencyConfig) history.FrecencyWeights {
+	return history.FrecencyWeights{
+		Frequency: cfg.Frequency,
+		Recency:   cfg.Recency,
+		HalfLife:  time.Duration(cfg.HalfLifeHours * float64(time.Hour)),
 	}
-
-	// Check if search results changed
-	previousCount := len(model.FilteredCommands)
-	searchResultsChanged := previousCount != len(filtered)
-
-	model.FilteredCommands = filtered
-	model.List.SetItems(components.CommandsToListItems(filtered))
-
-	// Adjust list index if search results changed
-	if searchResultsChanged {
-		model = adjustListIndex(model, len(filtered))
-	}
-
-	return model
 }
 
 // adjustListIndex ensures the list index is valid after search results change
@@ -3,8 +3,11 @@ package tui
 import (
 	"strings"
 
+	"sheek/internal/history"
 	"sheek/internal/tui/components"
 	"sheek/internal/tui/styles"
+
+	"github.com/charmbracelet/lipgloss"
 )
 
 // View renders the application UI
@@ -21,29 +24,168 @@ func View(model Model) string {
 	searchBar := components.RenderSearchComponent(
 		"> ",
 		inputContent,
-		model.SearchMode.String(),
+		modeLabel(model),
+		model.SearchErr != nil,
 		model.Width,
 		model.Config.Margin,
 	)
 	b.WriteString(searchBar)
 	b.WriteString("\n")
 
+	// Occurrence badges only make sense once repeats are collapsed
+	var usage history.UsageStats
+	if model.DedupeMode != history.DedupeNone {
+		usage = model.Usage
+	}
+
+	// Give the preview pane its share of the terminal before laying out the list
+	listWidth := model.Width
+	position := previewPosition(model)
+	previewWidth, previewHeight := previewPane(model)
+	if model.ShowPreview && position == components.PreviewRight {
+		listWidth = model.Width - previewWidth
+	}
+
 	// Pass config values to list component
 	listView := components.RenderListComponent(
 		model.FilteredCommands,
 		model.FuzzyPositions,
 		model.List.Index(),
-		model.Width,
+		listWidth,
 		model.Height,
-		model.Input.Value(),
-		components.SearchMode(model.SearchMode),
+		model.FilteredQuery,
+		components.SearchMode(model.FilteredMode),
+		model.FilteredCase,
 		model.Config.MaxItems,
 		model.Config.Height,
 		model.Config.Margin,
 		model.Config.ShowTimestamp,
+		model.Config.ShowDuration,
+		usage,
 	)
-	b.WriteString(listView)
+
+	switch {
+	case !model.ShowPreview:
+		b.WriteString(listView)
+	case position == components.PreviewRight:
+		preview := renderPreview(model, previewWidth, previewHeight)
+		b.WriteString(lipgloss.JoinHorizontal(lipgloss.Top, listView, preview))
+	default:
+		b.WriteString(listView)
+		b.WriteString("\n")
+		b.WriteString(renderPreview(model, previewWidth, previewHeight))
+	}
 	b.WriteString("\n")
 
 	return b.String()
 }
+
+const (
+	// previewListPercent is the share of the terminal width the list keeps beside a preview pane on the right
+	previewListPercent = 55
+	// minRightPreviewWidth is the narrowest terminal with room for a preview pane on the right;
+	// narrower ones place it at the bottom
+	minRightPreviewWidth = 100
+	// fixedViewHeight is the height of the search bar, the list's and the preview's borders
+	// and the trailing newline, which a preview pane at the bottom can't use
+	fixedViewHeight = 4 + 2 + 2 + 1
+)
+
+// previewPosition returns where the preview pane goes: the configured position,
+// unless the terminal is too narrow to fit it on the right
+func previewPosition(model Model) components.PreviewPosition {
+	position := components.PreviewPosition(model.Config.PreviewPosition)
+	if position == components.PreviewRight && model.Width > 0 && model.Width < minRightPreviewWidth {
+		return components.PreviewBottom
+	}
+	return position
+}
+
+// previewPane returns the terminal width given to the preview pane and the height of its content
+func previewPane(model Model) (width, height int) {
+	if previewPosition(model) == components.PreviewRight {
+		return model.Width - model.Width*previewListPercent/100, model.Config.Height
+	}
+	return model.Width, bottomPreviewHeight(model)
+}
+
+// renderPreview renders the preview pane: the output of the configured preview
+// command, or the selected command's details without one
+func renderPreview(model Model, width, height int) string {
+	if model.Config.PreviewCommand == "" {
+		return components.RenderPreviewComponent(selectedCommand(model), width, height, model.Config.Margin)
+	}
+
+	var status string
+	switch {
+	case model.PreviewRunning:
+		status = "running…"
+	case model.PreviewErr != nil:
+		status = model.PreviewErr.Error()
+	}
+	return components.RenderPreviewOutputComponent(
+		model.PreviewLines,
+		model.PreviewScroll,
+		status,
+		width,
+		height,
+		model.Config.Margin,
+	)
+}
+
+// bottomPreviewHeight returns the content height of a preview pane below the list:
+// as tall as the list, but no taller than the terminal leaves room for
+func bottomPreviewHeight(model Model) int {
+	height := model.Config.Height
+	if model.Height > 0 {
+		height = min(height, model.Height-model.Config.Height-fixedViewHeight)
+	}
+	return max(height, 1)
+}
+
+// selectedCommand returns the command selected in the list, or nil if there is none
+func selectedCommand(model Model) *history.Command {
+	i := model.List.Index()
+	if i < 0 || i >= len(model.FilteredCommands) {
+		return nil
+	}
+	return &model.FilteredCommands[i]
+}
+
+// sortModeLabels are shown in the mode badge for non-default sort modes
+var sortModeLabels = map[history.SortMode]string{
+	history.SortModeFrecency: "Frecency",
+	history.SortModeDuration: "Duration",
+}
+
+// dedupeModeLabels are shown in the mode badge when repeated commands are collapsed
+var dedupeModeLabels = map[history.DedupeMode]string{
+	history.DedupeKeepMostRecent: "Unique",
+	history.DedupeKeepFirst:      "Unique (first)",
+}
+
+// caseModeLabels describe how the query treats case
+var caseModeLabels = map[history.CaseMode]string{
+	history.CaseSmart:       "Smart case",
+	history.CaseSensitive:   "Match case",
+	history.CaseInsensitive: "Ignore case",
+}
+
+// modeLabel describes the active search, case, sort and dedupe modes for the mode badge,
+// and whether a search is still running or the query is invalid
+func modeLabel(model Model) string {
+	label := model.SearchMode.String() + " · " + caseModeLabels[model.CaseMode]
+	if sortLabel, ok := sortModeLabels[model.SortMode]; ok {
+		label += " · " + sortLabel
+	}
+	if dedupeLabel, ok := dedupeModeLabels[model.DedupeMode]; ok {
+		label += " · " + dedupeLabel
+	}
+	if model.SearchErr != nil {
+		label += " · invalid"
+	}
+	if model.Searching {
+		label += " · searching…"
+	}
+	return label
+}
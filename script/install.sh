@@ -167,6 +167,12 @@ elif [ "$CURRENT_SHELL" = "bash" ]; then
     echo -e "    ${BOLD}source ~/.bashrc${NC}"
     echo ""
     echo "  Or restart your terminal."
+elif [ "$CURRENT_SHELL" = "fish" ]; then
+    echo -e "${YELLOW}For fish:${NC}"
+    echo "  Add this line to ~/.config/fish/config.fish:"
+    echo -e "    ${BOLD}source $SCRIPT_DIR/keybinds.fish${NC}"
+    echo ""
+    echo "  Or restart your terminal."
 else
     echo -e "${YELLOW}Detected shell: $CURRENT_SHELL${NC}"
     echo ""
@@ -178,11 +184,16 @@ else
     echo "  Add this line to ~/.bashrc:"
     echo -e "    ${BOLD}source $SCRIPT_DIR/keybinds.bash${NC}"
     echo ""
+    echo -e "${YELLOW}For fish:${NC}"
+    echo "  Add this line to ~/.config/fish/config.fish:"
+    echo -e "    ${BOLD}source $SCRIPT_DIR/keybinds.fish${NC}"
+    echo ""
 fi
 
 echo ""
 echo "Keybind files location:"
 echo "  - $SCRIPT_DIR/keybinds.zsh (for zsh)"
 echo "  - $SCRIPT_DIR/keybinds.bash (for bash)"
+echo "  - $SCRIPT_DIR/keybinds.fish (for fish)"
 echo ""
 echo -e "${GREEN}After setting up, press Ctrl+T to search command history!${NC}"
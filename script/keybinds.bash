@@ -38,13 +38,17 @@ sheek-bash-widget() {
         return 1
     fi
 
+    # Flush this session's commands so they show up in the list
+    history -a
+
     local selected
     local cursor_pos="${READLINE_POINT:-0}"
     local current_line="${READLINE_LINE:-}"
     local prompt_fragment="${current_line:0:$cursor_pos}"
-    # Preserve TERM and COLORTERM for color support and pass the current prompt
+    # Preserve TERM and COLORTERM for color support, pass the current prompt and
+    # tell sheek which history file to read
     # Capture stdout for command, but allow stderr to show (for command preview)
-    selected=$(SHEEK_INITIAL_QUERY="$prompt_fragment" TERM="${TERM:-xterm-256color}" COLORTERM="${COLORTERM:-truecolor}" "$SHEEK_BIN")
+    selected=$(SHEEK_INITIAL_QUERY="$prompt_fragment" SHEEK_SHELL=bash HISTFILE="$HISTFILE" TERM="${TERM:-xterm-256color}" COLORTERM="${COLORTERM:-truecolor}" "$SHEEK_BIN")
     
     if [ -n "$selected" ]; then
         # Remove trailing newline if any
@@ -66,7 +70,7 @@ sheek() {
 
     local selected
     # Preserve TERM and COLORTERM for color support
-    selected=$(TERM="${TERM:-xterm-256color}" COLORTERM="${COLORTERM:-truecolor}" "$SHEEK_BIN")
+    selected=$(SHEEK_SHELL=bash HISTFILE="$HISTFILE" TERM="${TERM:-xterm-256color}" COLORTERM="${COLORTERM:-truecolor}" "$SHEEK_BIN")
     
     if [ -n "$selected" ]; then
         # Remove trailing newline if any
@@ -0,0 +1,60 @@
+# sheek - Ctrl+T keybinding for fish
+# Add this to your ~/.config/fish/config.fish: source /path/to/sheek/script/keybinds.fish
+
+set -g SHEEK_BIN ""
+set -g _SHEEK_BIN_WARNED 0
+
+function _sheek_require_binary
+    if test -n "$SHEEK_BIN"; and test -x "$SHEEK_BIN"
+        return 0
+    end
+
+    for candidate in "$HOME/.local/bin/sheek" "$HOME/go/bin/sheek" "/usr/local/bin/sheek" "/usr/bin/sheek"
+        if test -x "$candidate"
+            set -g SHEEK_BIN "$candidate"
+            return 0
+        end
+    end
+
+    set -l candidate (command -v sheek)
+    if test -n "$candidate"; and test -x "$candidate"
+        set -g SHEEK_BIN "$candidate"
+        return 0
+    end
+
+    if test "$_SHEEK_BIN_WARNED" -eq 0
+        echo "sheek: binary not found. Install it with ./script/install.sh and reload your shell." >&2
+        set -g _SHEEK_BIN_WARNED 1
+    end
+
+    return 1
+end
+
+function sheek-fish-widget
+    if not _sheek_require_binary
+        return 1
+    end
+
+    # Everything left of the cursor becomes the initial query
+    set -l prompt_fragment (commandline --cut-at-cursor)
+    set -q TERM[1]; or set -l TERM xterm-256color
+    set -q COLORTERM[1]; or set -l COLORTERM truecolor
+    # Preserve TERM and COLORTERM for color support, pass current prompt fragment and
+    # tell sheek to read fish history
+    # Capture stdout for command, but allow stderr to show (for command preview)
+    set -l selected (env SHEEK_INITIAL_QUERY="$prompt_fragment" SHEEK_SHELL=fish TERM="$TERM" COLORTERM="$COLORTERM" "$SHEEK_BIN" | string collect)
+
+    if test -n "$selected"
+        # Replace the commandline buffer with the selected command
+        commandline --replace -- "$selected"
+        commandline --cursor (string length -- "$selected")
+    end
+
+    commandline -f repaint
+end
+
+# Bind Ctrl+T to sheek widget in both default and vi insert modes
+bind \ct sheek-fish-widget
+if bind -M insert > /dev/null 2>&1
+    bind -M insert \ct sheek-fish-widget
+end